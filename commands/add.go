@@ -1,88 +1,197 @@
 package commands
 
 import (
-	"encoding/json"
 	"fmt"
+	"io"
 	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
 
 	"github.com/hanzala211/mini-git/common"
 	"github.com/spf13/cobra"
 )
 
-func AddFileToIndex(repoRoot string, filePath string, index common.Index) {
-	absFilePath, _ := filepath.Abs(filePath)
-	if !strings.HasPrefix(absFilePath, repoRoot) {
-		log.Fatalf("file %s is outside repository", filePath)
-	}
-	content, err := os.ReadFile(absFilePath)
-	if err != nil {
-		log.Fatalf("Failed to read file: %v", err)
-	}
-	hash, err := common.WriteObject(repoRoot, content, common.BlobFile, filePath)
-	if err != nil {
-		log.Fatal(err)
-	}
-	relativeFilePath := filepath.ToSlash(filePath)
-	index[relativeFilePath] = hash
+// stagedFile is the outcome of hashing one file, added is set when its blob
+// was not in the object store yet
+type stagedFile struct {
+	path  string
+	sha   string
+	added bool
+	err   error
 }
 
-func AddCommand(cmd *cobra.Command, args []string) {
-	repoPath, err := common.FindRepoRoot()
+// collectAddPaths expands the arguments into the files to add, in walk order
+// and without duplicates, so the same arguments always stage the same way
+func collectAddPaths(repoRoot string, args []string) []string {
+	minigitDir, err := filepath.Abs(filepath.Join(repoRoot, common.RootDir)) // skipped during the recursive walk
 	if err != nil {
 		log.Fatal(err)
 	}
-	minigitDir, err := filepath.Abs(filepath.Join(repoPath, common.RootDir)) // find the absolute path to minigit dir to skip it during
-	// recursive walk
+	gitDir, err := filepath.Abs(filepath.Join(repoRoot, ".git"))
 	if err != nil {
 		log.Fatal(err)
 	}
-	gitDir, err := filepath.Abs(filepath.Join(repoPath, ".git")) // find the absolute path to git dir to skip it during
-	// recursive walk
-	if err != nil {
-		log.Fatal(err)
-	}
-	indexBytes, err := os.ReadFile(filepath.Join(repoPath, common.RootDir, common.IndexFile))
-	if err != nil {
-		log.Fatal("Failed to read index")
+	var paths []string
+	seen := make(map[string]bool)
+	addPath := func(path string) {
+		absPath, _ := filepath.Abs(path)
+		if !strings.HasPrefix(absPath, repoRoot) {
+			log.Fatalf("file %s is outside repository", path)
+		}
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
 	}
-	var index common.Index
-	json.Unmarshal(indexBytes, &index)
-
 	for _, pathArg := range args {
 		stat, err := os.Stat(pathArg)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error stating path %s: %v\n", pathArg, err)
 			continue // Skip to the next argument
 		}
-		if stat.IsDir() {
-			err := filepath.Walk(pathArg, func(path string, info fs.FileInfo, err error) error { // filepath.Walk to recursively walk the directory
-				if err != nil {
-					return err // Handle error from walking
+		if !stat.IsDir() {
+			addPath(pathArg)
+			continue
+		}
+		err = filepath.WalkDir(pathArg, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			absPath, _ := filepath.Abs(path)
+			if absPath == minigitDir { // the folder, or the file of a linked worktree
+				if entry.IsDir() {
+					return filepath.SkipDir
 				}
-				absPath, _ := filepath.Abs(path)
-				if info.IsDir() && (absPath == minigitDir || absPath == gitDir) {
+				return nil
+			}
+			if entry.IsDir() {
+				if absPath == gitDir {
 					return filepath.SkipDir
 				}
-
-				if !info.IsDir() {
-					AddFileToIndex(repoPath, path, index)
+				// another repository or a linked worktree inside this one has its own files
+				if _, err := os.Stat(filepath.Join(path, common.RootDir)); err == nil && absPath != repoRoot {
+					return filepath.SkipDir
 				}
 				return nil
-			})
-			if err != nil {
-				log.Fatal(err)
 			}
-		} else {
-			AddFileToIndex(repoPath, pathArg, index)
+			addPath(path)
+			return nil
+		})
+		if err != nil {
+			log.Fatal(err)
 		}
 	}
-	newIndex, err := json.MarshalIndent(index, "", "  ")
+	return paths
+}
+
+// hashFiles reads, hashes and writes the blobs of paths with at most jobs
+// files in flight, the results are in the order of paths
+func hashFiles(repoRoot string, lfs *lfsTracker, paths []string, jobs int, bar *progress) []stagedFile {
+	results := make([]stagedFile, len(paths))
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				results[i] = hashFile(repoRoot, lfs, paths[i])
+				bar.Add(1)
+			}
+		}()
+	}
+	for i := range paths {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+	bar.Done()
+	return results
+}
+
+// hashFile streams a file into the object store, it is hashed once first so
+// an unchanged file is never compressed again. Files lfs tracks are stored in
+// the large file store and committed as a pointer.
+func hashFile(repoRoot string, lfs *lfsTracker, path string) stagedFile {
+	result := stagedFile{path: path}
+	file, err := os.Open(path)
+	if err != nil {
+		result.err = fmt.Errorf("failed to read %s: %w", path, err)
+		return result
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		result.err = fmt.Errorf("failed to read %s: %w", path, err)
+		return result
+	}
+	if relPath, err := repoRelativePath(repoRoot, path); err == nil && lfs.tracks(relPath) {
+		pointer, err := cleanFile(repoRoot, file, info.Size(), true)
+		if err != nil {
+			result.err = fmt.Errorf("failed to store large file %s: %w", path, err)
+			return result
+		}
+		result.added = !common.ObjectExists(repoRoot, common.HashObject(pointer, common.BlobFile))
+		result.sha, result.err = common.WriteObject(repoRoot, pointer, common.BlobFile, "")
+		return result
+	}
+	if result.sha, err = common.HashObjectFromReader(file, info.Size(), common.BlobFile); err != nil {
+		result.err = fmt.Errorf("failed to hash %s: %w", path, err)
+		return result
+	}
+	if common.ObjectExists(repoRoot, result.sha) {
+		return result
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		result.err = fmt.Errorf("failed to read %s: %w", path, err)
+		return result
+	}
+	result.sha, result.added, err = common.WriteObjectFromReader(repoRoot, file, info.Size(), common.BlobFile)
+	if err != nil {
+		result.err = fmt.Errorf("failed to add %s: %w", path, err)
+	}
+	return result
+}
+
+// AddCommand runs no hook, git has none for add and hooks call add themselves to stage fixes
+func AddCommand(cmd *cobra.Command, args []string) {
+	repoPath, err := common.FindRepoRoot()
+	if err != nil {
+		log.Fatal(err)
+	}
+	jobs, _ := cmd.Flags().GetInt("jobs")
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+	lfs, err := loadLFSTracker(repoPath)
 	if err != nil {
-		log.Fatal("Failed to marshal index")
+		log.Fatal(err)
+	}
+	paths := collectAddPaths(repoPath, args)
+	results := hashFiles(repoPath, lfs, paths, jobs, newProgress("Hashing objects", len(paths), wantProgress(cmd)))
+
+	// objects are written first and the index is only locked to merge the result in
+	staged := make(common.Index)
+	for _, result := range results {
+		if result.err != nil {
+			log.Fatal(result.err)
+		}
+		if result.added {
+			fmt.Println("adding file", result.path)
+		}
+		staged[filepath.ToSlash(result.path)] = result.sha
+	}
+	err = common.UpdateIndex(repoPath, func(index common.Index) error {
+		for path, sha := range staged {
+			index[path] = sha
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
 	}
-	os.WriteFile(filepath.Join(repoPath, common.RootDir, common.IndexFile), newIndex, 0644)
 }
@@ -0,0 +1,341 @@
+package commands
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hanzala211/mini-git/common"
+	"github.com/spf13/cobra"
+)
+
+// archiveWriter is a tar or zip file being written, entries are added in tree order
+type archiveWriter interface {
+	WriteDir(name string) error
+	WriteFile(name string, mode int64, size int64, content io.Reader) error
+	Close() error
+}
+
+type tarArchive struct {
+	tw      *tar.Writer
+	gz      *gzip.Writer // nil for plain tar
+	modTime time.Time
+}
+
+func newTarArchive(out io.Writer, compress bool, modTime time.Time, commitSha string) (*tarArchive, error) {
+	archive := &tarArchive{modTime: modTime}
+	if compress {
+		archive.gz = gzip.NewWriter(out)
+		out = archive.gz
+	}
+	archive.tw = tar.NewWriter(out)
+	if commitSha != "" { // like git, record the commit in a pax header so it can be found later
+		header := &tar.Header{
+			Typeflag:   tar.TypeXGlobalHeader,
+			Name:       "pax_global_header",
+			PAXRecords: map[string]string{"comment": commitSha},
+			Format:     tar.FormatPAX,
+		}
+		if err := archive.tw.WriteHeader(header); err != nil {
+			return nil, err
+		}
+	}
+	return archive, nil
+}
+
+func (a *tarArchive) WriteDir(name string) error {
+	return a.tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: name + "/", Mode: 0755, ModTime: a.modTime})
+}
+
+func (a *tarArchive) WriteFile(name string, mode int64, size int64, content io.Reader) error {
+	header := &tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: mode, Size: size, ModTime: a.modTime}
+	if err := a.tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := io.Copy(a.tw, content)
+	return err
+}
+
+func (a *tarArchive) Close() error {
+	if err := a.tw.Close(); err != nil {
+		return err
+	}
+	if a.gz != nil {
+		return a.gz.Close()
+	}
+	return nil
+}
+
+type zipArchive struct {
+	zw      *zip.Writer
+	modTime time.Time
+}
+
+func newZipArchive(out io.Writer, modTime time.Time, commitSha string) (*zipArchive, error) {
+	zw := zip.NewWriter(out)
+	if err := zw.SetComment(commitSha); err != nil {
+		return nil, err
+	}
+	return &zipArchive{zw: zw, modTime: modTime}, nil
+}
+
+func (a *zipArchive) WriteDir(name string) error {
+	header := &zip.FileHeader{Name: name + "/", Modified: a.modTime}
+	header.SetMode(os.ModeDir | 0755)
+	_, err := a.zw.CreateHeader(header)
+	return err
+}
+
+func (a *zipArchive) WriteFile(name string, mode int64, size int64, content io.Reader) error {
+	header := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: a.modTime}
+	header.SetMode(os.FileMode(mode))
+	w, err := a.zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, content)
+	return err
+}
+
+func (a *zipArchive) Close() error {
+	return a.zw.Close()
+}
+
+// fileMode turns a tree entry mode into permission bits for the archive
+func fileMode(treeMode string) int64 {
+	if treeMode == "100755" {
+		return 0755
+	}
+	return 0644
+}
+
+// writeParentDirs writes the entries for dir and its parents that are not in the archive yet
+func writeParentDirs(archive archiveWriter, prefix string, dir string, written map[string]bool) error {
+	if dir == "" || dir == "." || written[dir] {
+		return nil
+	}
+	if err := writeParentDirs(archive, prefix, path.Dir(dir), written); err != nil {
+		return err
+	}
+	written[dir] = true
+	return archive.WriteDir(prefix + dir)
+}
+
+// treeArchiver walks a tree into an archive
+type treeArchiver struct {
+	repoRoot  string
+	archive   archiveWriter
+	lfs       *lfsTracker // decides which blobs are large file pointers
+	prefix    string
+	pathspecs []string
+	matched   map[string]bool // pathspecs that matched a file
+	written   map[string]bool // directories already in the archive
+}
+
+// archiveTree writes the blobs of a tree to the archive one at a time, a
+// directory entry is written just before the first file inside it
+func (a *treeArchiver) archiveTree(treeSha string, treePath string) error {
+	treeData, err := common.ReadObject(a.repoRoot, treeSha)
+	if err != nil {
+		return fmt.Errorf("failed to read tree %s: %w", treeSha, err)
+	}
+	entries, err := parseTreeToMap(treeData)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		entry := entries[name]
+		entryPath := name
+		if treePath != "" {
+			entryPath = treePath + "/" + name
+		}
+		if modeToType(entry.Mode) == common.TreeFile {
+			if err := a.archiveTree(entry.SHA, entryPath); err != nil {
+				return err
+			}
+			continue
+		}
+		if !matchesPathspec(entryPath, a.pathspecs) {
+			continue
+		}
+		for _, spec := range a.pathspecs {
+			if matchesPathspec(entryPath, []string{spec}) {
+				a.matched[spec] = true
+			}
+		}
+		if err := writeParentDirs(a.archive, a.prefix, treePath, a.written); err != nil {
+			return err
+		}
+		if err := a.archiveBlob(entry.SHA, entryPath, fileMode(entry.Mode)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// archiveBlob streams one blob into the archive, it is never held in memory
+// whole. A large file pointer goes in as the content it points to, like checkout.
+func (a *treeArchiver) archiveBlob(blobSha string, entryPath string, mode int64) error {
+	blob, err := common.OpenObject(a.repoRoot, blobSha)
+	if err != nil {
+		return fmt.Errorf("failed to read blob %s: %w", blobSha, err)
+	}
+	defer blob.Close()
+	content, size, closer, err := smudgeBlob(a.repoRoot, a.lfs, blob, filepath.Join(a.repoRoot, filepath.FromSlash(entryPath)))
+	if err != nil {
+		return fmt.Errorf("failed to read blob %s: %w", blobSha, err)
+	}
+	if closer != nil {
+		defer closer.Close()
+	}
+	name := a.prefix + entryPath
+	if err := a.archive.WriteFile(name, mode, size, content); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// writeArchive writes the whole archive of treeSha to out. A prefix that names
+// a directory gets entries of its own, so the archive extracts into it.
+func writeArchive(repoRoot string, out io.Writer, format string, treeSha string, commitSha string, modTime time.Time, prefix string, pathspecs []string) error {
+	lfs, err := treeLFSTracker(repoRoot, treeSha)
+	if err != nil {
+		return err
+	}
+	var archive archiveWriter
+	if format == "zip" {
+		archive, err = newZipArchive(out, modTime, commitSha)
+	} else {
+		archive, err = newTarArchive(out, format == "tar.gz", modTime, commitSha)
+	}
+	if err != nil {
+		return err
+	}
+	if err := writeParentDirs(archive, "", path.Dir(prefix+"x"), make(map[string]bool)); err != nil {
+		return err
+	}
+	archiver := &treeArchiver{
+		repoRoot:  repoRoot,
+		archive:   archive,
+		lfs:       lfs,
+		prefix:    prefix,
+		pathspecs: pathspecs,
+		matched:   make(map[string]bool),
+		written:   make(map[string]bool),
+	}
+	if err := archiver.archiveTree(treeSha, ""); err != nil {
+		return err
+	}
+	for _, spec := range pathspecs {
+		if !archiver.matched[spec] {
+			return fmt.Errorf("pathspec '%s' did not match any files", spec)
+		}
+	}
+	return archive.Close()
+}
+
+// archiveFormat picks the format from --format or else from the output file name
+func archiveFormat(format string, output string) (string, error) {
+	if format == "" {
+		switch {
+		case strings.HasSuffix(output, ".tar.gz"), strings.HasSuffix(output, ".tgz"):
+			format = "tar.gz"
+		case strings.HasSuffix(output, ".zip"):
+			format = "zip"
+		default:
+			format = "tar"
+		}
+	}
+	switch format {
+	case "tar", "tar.gz", "tgz", "zip":
+		return strings.Replace(format, "tgz", "tar.gz", 1), nil
+	}
+	return "", fmt.Errorf("unknown archive format %q, use tar, tar.gz or zip", format)
+}
+
+func ArchiveCommand(cmd *cobra.Command, args []string) {
+	repoPath, err := common.FindRepoRoot()
+	if err != nil {
+		log.Fatal(err)
+	}
+	output, _ := cmd.Flags().GetString("output")
+	formatFlag, _ := cmd.Flags().GetString("format")
+	prefix, _ := cmd.Flags().GetString("prefix")
+	format, err := archiveFormat(formatFlag, output)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	sha, err := common.ResolveRevision(repoPath, args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if sha, err = common.PeelTag(repoPath, sha); err != nil { // a tag archives what it tags
+		log.Fatal(err)
+	}
+	treeSha, err := common.ResolveTree(repoPath, args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	// entries get the commit time so the same commit always gives the same archive
+	modTime, commitSha := time.Now(), ""
+	if treeSha != sha {
+		commit, err := common.ReadCommit(repoPath, sha)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if modTime, err = common.ParseTimestamp(commit.Timestamp); err != nil {
+			log.Fatal(err)
+		}
+		commitSha = sha
+	}
+	var pathspecs []string
+	for _, arg := range args[1:] {
+		spec, err := repoRelativePath(repoPath, arg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		pathspecs = append(pathspecs, spec)
+	}
+
+	if output == "" {
+		if err := writeArchive(repoPath, os.Stdout, format, treeSha, commitSha, modTime, prefix, pathspecs); err != nil {
+			log.Fatalf("failed to write archive: %v", err)
+		}
+		return
+	}
+	// the archive is written next to output and renamed into place, so a
+	// failure never leaves a partial archive behind under the real name
+	file, err := os.CreateTemp(filepath.Dir(output), "."+filepath.Base(output)+".tmp-*")
+	if err != nil {
+		log.Fatalf("failed to create %s: %v", output, err)
+	}
+	err = writeArchive(repoPath, file, format, treeSha, commitSha, modTime, prefix, pathspecs)
+	if err == nil {
+		err = file.Chmod(0644)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), output)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		log.Fatalf("failed to write %s: %v", output, err)
+	}
+}
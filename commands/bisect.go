@@ -0,0 +1,451 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/bits"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/hanzala211/mini-git/common"
+	"github.com/spf13/cobra"
+)
+
+// a bisection keeps what HEAD was on when it started in .minigit/bisect/start
+// and a replayable log of every mark in .minigit/bisect/log. The marks are refs
+// (refs/bisect/bad, refs/bisect/good-<sha>, refs/bisect/skip-<sha>) so gc keeps
+// the commits around and they can be used as revisions.
+const (
+	bisectDir     = "bisect"
+	bisectRefsDir = "refs/bisect"
+)
+
+func bisectStatePath(repoRoot string, name string) string {
+	return filepath.Join(common.MinigitDir(repoRoot), bisectDir, name)
+}
+
+func requireBisect(repoRoot string) {
+	if _, err := os.Stat(bisectStatePath(repoRoot, "start")); os.IsNotExist(err) {
+		log.Fatal("not bisecting, run \"mini-git bisect start\" first")
+	}
+}
+
+func appendBisectLog(repoRoot string, text string) error {
+	file, err := os.OpenFile(bisectStatePath(repoRoot, "log"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.WriteString(text)
+	return err
+}
+
+// readBisectMarks returns the bad commit ("" when unknown), the good commits and the skipped ones
+func readBisectMarks(repoRoot string) (string, []string, map[string]bool, error) {
+	refs, err := common.ListRefs(repoRoot)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	bad := ""
+	var good []string
+	skipped := make(map[string]bool)
+	for name, sha := range refs {
+		term, found := strings.CutPrefix(name, bisectRefsDir+"/")
+		switch {
+		case !found:
+		case term == "bad":
+			bad = sha
+		case strings.HasPrefix(term, "good-"):
+			good = append(good, sha)
+		case strings.HasPrefix(term, "skip-"):
+			skipped[sha] = true
+		}
+	}
+	return bad, good, skipped, nil
+}
+
+// bisectMark records revs as good, bad or skipped, HEAD when no rev is given
+func bisectMark(repoRoot string, term string, revs []string) {
+	if len(revs) == 0 {
+		revs = []string{common.HEAD}
+	}
+	if term == "bad" && len(revs) > 1 {
+		log.Fatal("only one commit can be marked bad")
+	}
+	for _, rev := range revs {
+		sha, err := common.ResolveCommit(repoRoot, rev)
+		if err != nil {
+			log.Fatal(err)
+		}
+		commit, err := common.ReadCommit(repoRoot, sha)
+		if err != nil {
+			log.Fatal(err)
+		}
+		refName := bisectRefsDir + "/" + term
+		if term != "bad" {
+			refName += "-" + sha
+		}
+		if err := common.WriteRef(repoRoot, refName, sha); err != nil {
+			log.Fatal(err)
+		}
+		entry := fmt.Sprintf("# %s: [%s] %s\nbisect %s %s\n", term, sha, firstLine(commit.Message), term, sha)
+		if err := appendBisectLog(repoRoot, entry); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// bisectMidpoint picks the testable commit that splits the candidates most
+// evenly: the one whose number of ancestors among the candidates is closest to half
+func bisectMidpoint(repoRoot string, candidates []string, testable []string) (string, error) {
+	inRange := make(map[string]bool)
+	parents := make(map[string][]string)
+	for _, sha := range candidates {
+		commit, err := common.ReadCommit(repoRoot, sha)
+		if err != nil {
+			return "", err
+		}
+		inRange[sha] = true
+		parents[sha] = commit.Parents
+	}
+	best, bestScore := "", -1
+	for _, sha := range testable {
+		seen := map[string]bool{sha: true}
+		queue := []string{sha}
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+			for _, parent := range parents[current] {
+				if inRange[parent] && !seen[parent] {
+					seen[parent] = true
+					queue = append(queue, parent)
+				}
+			}
+		}
+		if score := min(len(seen), len(candidates)-len(seen)); score > bestScore {
+			best, bestScore = sha, score
+		}
+	}
+	return best, nil
+}
+
+// switchWorktree moves the working tree and index from the current index to a commit
+func switchWorktree(repoRoot string, sha string) error {
+	current, err := common.ReadIndex(repoRoot)
+	if err != nil {
+		return err
+	}
+	target, err := commitIndex(repoRoot, sha)
+	if err != nil {
+		return err
+	}
+	if err := updateWorktree(repoRoot, current, target); err != nil {
+		return err
+	}
+	return common.WriteIndex(repoRoot, target)
+}
+
+// checkoutDetached moves the working tree, index and HEAD to a commit
+func checkoutDetached(repoRoot string, sha string) error {
+	from := currentBranchName(repoRoot)
+	if from == common.HEAD {
+		from, _ = common.GetParentSha(repoRoot)
+	}
+	if err := switchWorktree(repoRoot, sha); err != nil {
+		return err
+	}
+	return common.SetHeadDetached(repoRoot, sha, fmt.Sprintf("checkout: moving from %s to %s", from, sha))
+}
+
+func printFirstBad(repoRoot string, sha string) error {
+	commit, err := common.ReadCommit(repoRoot, sha)
+	if err != nil {
+		return err
+	}
+	when, err := commit.AuthorTime()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s is the first bad commit\n", sha)
+	fmt.Printf("commit %s\nAuthor: %s\nDate:   %s\n\n", sha, commit.AuthorName(), when.Format("Mon Jan 2 15:04:05 2006 -0700"))
+	for _, line := range strings.Split(commit.Message, "\n") {
+		fmt.Printf("    %s\n", line)
+	}
+	return appendBisectLog(repoRoot, fmt.Sprintf("# first bad commit: [%s] %s\n", sha, firstLine(commit.Message)))
+}
+
+// bisectNext checks out the next commit to test, it returns true once the
+// first bad commit has been found or only skipped commits are left
+func bisectNext(repoRoot string) (bool, error) {
+	bad, good, skipped, err := readBisectMarks(repoRoot)
+	if err != nil {
+		return false, err
+	}
+	if bad == "" || len(good) == 0 {
+		switch {
+		case bad == "" && len(good) == 0:
+			fmt.Println("status: waiting for both good and bad commits")
+		case bad == "":
+			fmt.Println("status: waiting for a bad commit, good commit(s) known")
+		default:
+			fmt.Println("status: waiting for good commit(s), bad commit known")
+		}
+		return false, nil
+	}
+	excluded := make(map[string]bool)
+	for _, sha := range good {
+		ancestors, err := ancestorSet(repoRoot, sha)
+		if err != nil {
+			return false, err
+		}
+		for ancestor := range ancestors {
+			excluded[ancestor] = true
+		}
+	}
+	if excluded[bad] {
+		return false, fmt.Errorf("the bad commit %s is an ancestor of a good commit, were the marks swapped?", bad[:7])
+	}
+	candidates, err := commitsExcluding(repoRoot, excluded, bad)
+	if err != nil {
+		return false, err
+	}
+	var testable, untested []string
+	for _, sha := range candidates {
+		switch {
+		case sha == bad:
+		case skipped[sha]:
+			untested = append(untested, sha)
+		default:
+			testable = append(testable, sha)
+		}
+	}
+
+	if len(testable) == 0 {
+		if len(untested) == 0 {
+			return true, printFirstBad(repoRoot, bad)
+		}
+		fmt.Println("There are only 'skip'ped commits left to test.")
+		fmt.Println("The first bad commit could be any of:")
+		for _, sha := range append(untested, bad) {
+			fmt.Println(sha)
+		}
+		return true, nil
+	}
+	next, err := bisectMidpoint(repoRoot, candidates, testable)
+	if err != nil {
+		return false, err
+	}
+	if err := ensureClean(repoRoot, "bisect"); err != nil {
+		return false, err
+	}
+	if err := checkoutDetached(repoRoot, next); err != nil {
+		return false, err
+	}
+	commit, err := common.ReadCommit(repoRoot, next)
+	if err != nil {
+		return false, err
+	}
+	left := len(testable) / 2
+	fmt.Printf("Bisecting: %d revisions left to test after this (roughly %d steps)\n", left, bits.Len(uint(left)))
+	fmt.Printf("[%s] %s\n", next, firstLine(commit.Message))
+	return false, nil
+}
+
+func bisectStart(repoRoot string, args []string) {
+	if _, err := os.Stat(bisectStatePath(repoRoot, "start")); err == nil {
+		log.Fatal("already bisecting, run \"mini-git bisect reset\" first")
+	}
+	if err := ensureClean(repoRoot, "bisect"); err != nil {
+		log.Fatal(err)
+	}
+	start, err := common.GetHeadRef(repoRoot)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if start == common.HEAD { // detached, remember the commit itself
+		if start, err = common.GetParentSha(repoRoot); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(common.MinigitDir(repoRoot), bisectDir), 0755); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(bisectStatePath(repoRoot, "start"), []byte(start+"\n"), 0644); err != nil {
+		log.Fatalf("failed to write bisect state: %v", err)
+	}
+	if err := appendBisectLog(repoRoot, "bisect start "+strings.Join(args, " ")+"\n"); err != nil {
+		log.Fatal(err)
+	}
+	if len(args) > 0 {
+		bisectMark(repoRoot, "bad", args[:1])
+	}
+	if len(args) > 1 {
+		bisectMark(repoRoot, "good", args[1:])
+	}
+	if _, err := bisectNext(repoRoot); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// bisectReset ends the bisection and goes back to where it started, or to rev
+func bisectReset(repoRoot string, args []string) {
+	startContent, err := os.ReadFile(bisectStatePath(repoRoot, "start"))
+	if os.IsNotExist(err) {
+		fmt.Println("We are not bisecting.")
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	start := strings.TrimSpace(string(startContent))
+	if err := ensureClean(repoRoot, "bisect reset"); err != nil {
+		log.Fatal(err)
+	}
+	switch {
+	case len(args) > 0:
+		sha, err := common.ResolveCommit(repoRoot, args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := checkoutDetached(repoRoot, sha); err != nil {
+			log.Fatal(err)
+		}
+	case strings.HasPrefix(start, common.RefsDir+"/"):
+		sha, err := common.ReadRef(repoRoot, start)
+		if err != nil {
+			log.Fatal(err)
+		}
+		from, err := common.GetParentSha(repoRoot)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := switchWorktree(repoRoot, sha); err != nil {
+			log.Fatal(err)
+		}
+		branch := strings.TrimPrefix(start, "refs/heads/")
+		if err := common.SetHeadRef(repoRoot, start, fmt.Sprintf("checkout: moving from %s to %s", from, branch)); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		if err := checkoutDetached(repoRoot, start); err != nil {
+			log.Fatal(err)
+		}
+	}
+	// pack-refs --all may have moved the marks into packed-refs, so they are deleted as refs
+	refs, err := common.ListRefs(repoRoot)
+	if err != nil {
+		log.Fatal(err)
+	}
+	transaction := common.NewRefTransaction(repoRoot)
+	for name := range refs {
+		if strings.HasPrefix(name, bisectRefsDir+"/") {
+			transaction.Delete(name, "", "bisect reset")
+		}
+	}
+	if err := transaction.Commit(); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(common.MinigitDir(repoRoot), filepath.FromSlash(bisectRefsDir))); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(common.MinigitDir(repoRoot), bisectDir)); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// shellQuote quotes every argument for sh like git's sq_quote_argv, so the
+// shell hands the command exactly the arguments it was given
+func shellQuote(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		arg = strings.ReplaceAll(arg, "'", `'\''`)
+		quoted[i] = "'" + strings.ReplaceAll(arg, "!", `'\!'`) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
+
+// bisectRun marks commits with the exit code of a command until the search
+// ends: 0 is good, 125 is skip, 1 to 127 is bad and anything else stops the run
+func bisectRun(repoRoot string, args []string) {
+	if len(args) == 0 {
+		log.Fatal("usage: mini-git bisect run <cmd> [<args>...]")
+	}
+	command := shellQuote(args)
+	for {
+		bad, good, _, err := readBisectMarks(repoRoot)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if bad == "" || len(good) == 0 {
+			log.Fatal("bisect run needs a good and a bad commit, mark them first")
+		}
+		fmt.Printf("running %s\n", command)
+		run := exec.Command("sh", "-c", command)
+		run.Dir = repoRoot
+		run.Stdout, run.Stderr = os.Stdout, os.Stderr
+		code := 0
+		if err := run.Run(); err != nil {
+			var exitErr *exec.ExitError
+			if !errors.As(err, &exitErr) {
+				log.Fatalf("bisect run failed: %v", err)
+			}
+			code = exitErr.ExitCode()
+		}
+		term := "bad"
+		switch {
+		case code == 0:
+			term = "good"
+		case code == 125:
+			term = "skip"
+		case code < 0 || code >= 128:
+			log.Fatalf("bisect run failed: exit code %d from %s is < 0 or >= 128", code, command)
+		}
+		bisectMark(repoRoot, term, nil)
+		done, err := bisectNext(repoRoot)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if done {
+			fmt.Println("bisect found first bad commit")
+			return
+		}
+	}
+}
+
+func BisectCommand(cmd *cobra.Command, args []string) {
+	repoPath, err := common.FindRepoRoot()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(args) == 0 {
+		log.Fatal("usage: mini-git bisect [start|bad|good|skip|reset|log|run]")
+	}
+	action, args := args[0], args[1:]
+	switch action {
+	case "start":
+		bisectStart(repoPath, args)
+	case "bad", "good", "skip":
+		requireBisect(repoPath)
+		bisectMark(repoPath, action, args)
+		if _, err := bisectNext(repoPath); err != nil {
+			log.Fatal(err)
+		}
+	case "reset":
+		bisectReset(repoPath, args)
+	case "log":
+		requireBisect(repoPath)
+		content, err := os.ReadFile(bisectStatePath(repoPath, "log"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Print(string(content))
+	case "run":
+		requireBisect(repoPath)
+		bisectRun(repoPath, args)
+	default:
+		log.Fatalf("unknown bisect subcommand %q", action)
+	}
+}
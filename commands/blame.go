@@ -0,0 +1,268 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/hanzala211/mini-git/common"
+	"github.com/spf13/cobra"
+)
+
+// blameLine is the commit a line of the blamed file comes from and the line
+// number (1-based) it had in that commit
+type blameLine struct {
+	sha      string
+	origLine int
+}
+
+// blameFile attributes every line of path at commitSha to the commit that
+// introduced it. Starting at commitSha, the lines still unaccounted for are
+// handed to a parent whenever diffing the two versions shows them unchanged,
+// whatever no parent takes was written by the commit itself.
+func blameFile(repoRoot string, commitSha string, path string) ([]string, []blameLine, error) {
+	commits := make(map[string]*common.Commit)
+	blobs := make(map[string]string)
+	blobOf := func(sha string) (string, error) {
+		if blob, ok := blobs[sha]; ok {
+			return blob, nil
+		}
+		commit, err := common.ReadCommit(repoRoot, sha)
+		if err != nil {
+			return "", err
+		}
+		blob, err := blobAtPath(repoRoot, commit.Tree, path)
+		if err != nil {
+			return "", err
+		}
+		commits[sha], blobs[sha] = commit, blob
+		return blob, nil
+	}
+	linesOf := func(blob string) ([]string, error) {
+		content, err := common.ReadObject(repoRoot, blob)
+		if err != nil {
+			return nil, err
+		}
+		return splitLines(content), nil
+	}
+
+	finalBlob, err := blobOf(commitSha)
+	if err != nil {
+		return nil, nil, err
+	}
+	if finalBlob == "" {
+		return nil, nil, fmt.Errorf("no such path %s in %s", path, commitSha[:7])
+	}
+	finalLines, err := linesOf(finalBlob)
+	if err != nil {
+		return nil, nil, err
+	}
+	history, err := commitsBetween(repoRoot, "", commitSha)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// pending[sha] maps a line of the file in sha to the final lines it became
+	pending := map[string]map[int][]int{commitSha: {}}
+	for i := range finalLines {
+		pending[commitSha][i] = []int{i}
+	}
+	result := make([]blameLine, len(finalLines))
+	for i := len(history) - 1; i >= 0; i-- { // children before their parents
+		sha := history[i]
+		lines := pending[sha]
+		if len(lines) == 0 {
+			continue
+		}
+		delete(pending, sha)
+		blob, err := blobOf(sha)
+		if err != nil {
+			return nil, nil, err
+		}
+		current, err := linesOf(blob)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, parent := range commits[sha].Parents {
+			if len(lines) == 0 {
+				break
+			}
+			parentBlob, err := blobOf(parent)
+			if err != nil {
+				return nil, nil, err
+			}
+			if parentBlob == "" {
+				continue
+			}
+			if pending[parent] == nil {
+				pending[parent] = make(map[int][]int)
+			}
+			passed := pending[parent]
+			if parentBlob == blob {
+				for line, targets := range lines {
+					passed[line] = append(passed[line], targets...)
+				}
+				lines = nil
+				break
+			}
+			parentLines, err := linesOf(parentBlob)
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, edit := range diffLines(parentLines, current) {
+				if targets, ok := lines[edit.bIndex]; ok && edit.op == diffEqual {
+					passed[edit.aIndex] = append(passed[edit.aIndex], targets...)
+					delete(lines, edit.bIndex)
+				}
+			}
+		}
+		for line, targets := range lines {
+			for _, target := range targets {
+				result[target] = blameLine{sha: sha, origLine: line + 1}
+			}
+		}
+	}
+	return finalLines, result, nil
+}
+
+// parseLineRange reads a -L argument: "start,end", "start,+count" or "start,"
+// (to the end of the file), returning a 1-based inclusive range
+func parseLineRange(value string, total int) (int, int, error) {
+	startText, endText, _ := strings.Cut(value, ",")
+	start, err := strconv.Atoi(startText)
+	if err != nil || start < 1 {
+		return 0, 0, fmt.Errorf("invalid line range %q", value)
+	}
+	end := total
+	if count, ok := strings.CutPrefix(endText, "+"); ok {
+		n, err := strconv.Atoi(count)
+		if err != nil || n < 1 {
+			return 0, 0, fmt.Errorf("invalid line range %q", value)
+		}
+		end = start + n - 1
+	} else if endText != "" {
+		if end, err = strconv.Atoi(endText); err != nil || end < start {
+			return 0, 0, fmt.Errorf("invalid line range %q", value)
+		}
+	}
+	if start > total {
+		return 0, 0, fmt.Errorf("file has only %d lines", total)
+	}
+	return start, min(end, total), nil
+}
+
+// splitAuthor returns the name and the <email> of a commit's author
+func splitAuthor(commit *common.Commit) (string, string) {
+	author := commit.AuthorName()
+	if i := strings.LastIndexByte(author, '<'); i != -1 {
+		return strings.TrimSpace(author[:i]), author[i:]
+	}
+	if author == "" {
+		return "unknown", "<>"
+	}
+	return author, "<>"
+}
+
+func printBlame(repoRoot string, lines []string, blame []blameLine, start int, end int) {
+	type lineInfo struct{ sha, name, date string }
+	infos := make([]lineInfo, 0, end-start+1)
+	nameWidth := 0
+	for i := start - 1; i < end; i++ {
+		commit, err := common.ReadCommit(repoRoot, blame[i].sha)
+		if err != nil {
+			log.Fatal(err)
+		}
+		name, _ := splitAuthor(commit)
+		when, err := commit.AuthorTime()
+		if err != nil {
+			log.Fatal(err)
+		}
+		sha := blame[i].sha[:8]
+		if len(commit.Parents) == 0 { // like git, root commits are marked as boundaries
+			sha = "^" + blame[i].sha[:7]
+		}
+		infos = append(infos, lineInfo{sha, name, when.Format("2006-01-02 15:04:05 -0700")})
+		nameWidth = max(nameWidth, len(name))
+	}
+	numberWidth := len(strconv.Itoa(end))
+	for i, info := range infos {
+		line := strings.TrimSuffix(lines[start-1+i], "\n")
+		fmt.Printf("%s (%-*s %s %*d) %s\n", info.sha, nameWidth, info.name, info.date, numberWidth, start+i, line)
+	}
+}
+
+// printPorcelain prints the machine readable format: a "<sha> <orig> <final>"
+// header per line (plus the group size on the first line of a group), the
+// commit details the first time a commit shows up, then the line after a tab
+func printPorcelain(repoRoot string, path string, lines []string, blame []blameLine, start int, end int) {
+	seen := make(map[string]bool)
+	for i := start - 1; i < end; i++ {
+		current := blame[i]
+		header := fmt.Sprintf("%s %d %d", current.sha, current.origLine, i+1)
+		if i == start-1 || blame[i-1].sha != current.sha || blame[i-1].origLine != current.origLine-1 {
+			group := 1
+			for j := i + 1; j < end && blame[j].sha == current.sha && blame[j].origLine == current.origLine+j-i; j++ {
+				group++
+			}
+			header += fmt.Sprintf(" %d", group)
+		}
+		fmt.Println(header)
+		if !seen[current.sha] {
+			seen[current.sha] = true
+			commit, err := common.ReadCommit(repoRoot, current.sha)
+			if err != nil {
+				log.Fatal(err)
+			}
+			name, mail := splitAuthor(commit)
+			when, err := commit.AuthorTime()
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Printf("author %s\nauthor-mail %s\nauthor-time %d\nauthor-tz %s\n", name, mail, when.Unix(), when.Format("-0700"))
+			fmt.Printf("summary %s\n", firstLine(commit.Message))
+			if len(commit.Parents) == 0 {
+				fmt.Println("boundary")
+			}
+			fmt.Printf("filename %s\n", path)
+		}
+		fmt.Printf("\t%s\n", strings.TrimSuffix(lines[i], "\n"))
+	}
+}
+
+func BlameCommand(cmd *cobra.Command, args []string) {
+	repoPath, err := common.FindRepoRoot()
+	if err != nil {
+		log.Fatal(err)
+	}
+	rev := "HEAD"
+	if len(args) == 2 {
+		rev = args[1]
+	}
+	commitSha, err := common.ResolveCommit(repoPath, rev)
+	if err != nil {
+		log.Fatal(err)
+	}
+	path, err := repoRelativePath(repoPath, args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	lines, blame, err := blameFile(repoPath, commitSha, path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(lines) == 0 {
+		return
+	}
+	start, end := 1, len(lines)
+	if lineRange, _ := cmd.Flags().GetString("lines"); lineRange != "" {
+		if start, end, err = parseLineRange(lineRange, len(lines)); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if porcelain, _ := cmd.Flags().GetBool("porcelain"); porcelain {
+		printPorcelain(repoPath, path, lines, blame, start, end)
+		return
+	}
+	printBlame(repoPath, lines, blame, start, end)
+}
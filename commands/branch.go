@@ -3,8 +3,7 @@ package commands
 import (
 	"fmt"
 	"log"
-	"os"
-	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/hanzala211/mini-git/common"
@@ -12,37 +11,59 @@ import (
 )
 
 func listBranches(repoRoot string) {
-	filePath := filepath.Join(repoRoot, common.RootDir, common.RefsDir, common.HeadDir)
-	headRef, _ := common.GetHeadRef(repoRoot)
-	entries, err := os.ReadDir(filePath)
+	refs, err := common.ListRefs(repoRoot)
 	if err != nil {
 		log.Fatal(err)
 	}
-	currentBranch := strings.Split(headRef, "/")[2]
-	for _, entry := range entries {
-		if entry.Name() == currentBranch {
-			fmt.Println("*", entry.Name())
+	currentBranch := currentBranchName(repoRoot)
+	var branches []string
+	for refName := range refs {
+		if branch, ok := strings.CutPrefix(refName, "refs/heads/"); ok {
+			branches = append(branches, branch)
+		}
+	}
+	// a branch without commits is not in ListRefs but HEAD can still be on it
+	if _, listed := refs["refs/heads/"+currentBranch]; !listed && currentBranch != common.HEAD {
+		branches = append(branches, currentBranch)
+	}
+	sort.Strings(branches)
+	for _, branch := range branches {
+		if branch == currentBranch {
+			fmt.Println("*", branch)
+		} else if elsewhere, _ := worktreeWithBranch(repoRoot, "refs/heads/"+branch); elsewhere != "" {
+			fmt.Println("+", branch) // checked out in another worktree
 		} else {
-			fmt.Println(entry.Name())
+			fmt.Println(branch)
 		}
 	}
 }
 
+// currentBranchName returns the branch HEAD is on, or "HEAD" when it is detached
+func currentBranchName(repoRoot string) string {
+	headRef, err := common.GetHeadRef(repoRoot)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return strings.TrimPrefix(headRef, "refs/heads/")
+}
+
 func createBranch(repoRoot string, branchName string) {
-	filePath := filepath.Join(repoRoot, common.RootDir, common.RefsDir, common.HeadDir, branchName)
-	if _, err := os.Stat(filePath); err == nil {
+	refName := "refs/heads/" + branchName
+	if _, err := common.ReadRef(repoRoot, refName); err == nil {
 		log.Fatalf("branch %s already exists", branchName)
 	}
 	parentSha, err := common.GetParentSha(repoRoot)
 	if err != nil {
 		log.Fatalf("failed to get parent commit: %v", err)
 	}
-	if err := os.WriteFile(filePath, []byte(parentSha), 0644); err != nil {
+	// the empty old value makes the update fail if the branch appeared in the meantime
+	transaction := common.NewRefTransaction(repoRoot)
+	transaction.Update(refName, parentSha, "", "branch: Created from HEAD")
+	if err := transaction.Commit(); err != nil {
 		log.Fatalf("failed to create branch %s: %v", branchName, err)
 	}
-	headPath := filepath.Join(repoRoot, common.RootDir, common.HEAD)
-	err = os.WriteFile(headPath, []byte("ref: refs/heads/"+branchName), 0644)
-	if err != nil {
+	reason := fmt.Sprintf("checkout: moving from %s to %s", currentBranchName(repoRoot), branchName)
+	if err := common.SetHeadRef(repoRoot, refName, reason); err != nil {
 		log.Fatalf("failed to update head: %v", err)
 	}
 	fmt.Printf("Branch %s created and switched to it.\n", branchName)
@@ -0,0 +1,346 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hanzala211/mini-git/common"
+	"github.com/spf13/cobra"
+)
+
+const bundleSignature = "# mini-git bundle v1"
+
+// bundleHeader is the text part of a bundle, the object stream follows it.
+// Prerequisites are commits the bundle leaves out, so whoever reads it must
+// have them already.
+type bundleHeader struct {
+	Prerequisites []string
+	Refs          map[string]string
+}
+
+// a bundle file is the signature line, "-<sha>" for each prerequisite,
+// "<sha> <ref>" for each ref, a blank line and then the object stream
+func writeBundleHeader(w io.Writer, header *bundleHeader) error {
+	if _, err := fmt.Fprintln(w, bundleSignature); err != nil {
+		return err
+	}
+	for _, sha := range header.Prerequisites {
+		if _, err := fmt.Fprintf(w, "-%s\n", sha); err != nil {
+			return err
+		}
+	}
+	names := make([]string, 0, len(header.Refs))
+	for name := range header.Refs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, err := fmt.Fprintf(w, "%s %s\n", header.Refs[name], name); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// readBundleHeader reads the header and leaves reader at the start of the objects
+func readBundleHeader(reader *bufio.Reader) (*bundleHeader, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil || strings.TrimSuffix(line, "\n") != bundleSignature {
+		return nil, fmt.Errorf("not a mini-git bundle")
+	}
+	header := &bundleHeader{Refs: make(map[string]string)}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("truncated bundle header: %w", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return header, nil
+		}
+		if sha, ok := strings.CutPrefix(line, "-"); ok {
+			header.Prerequisites = append(header.Prerequisites, sha)
+			continue
+		}
+		sha, name, ok := strings.Cut(line, " ")
+		if !ok || len(sha) != 40 {
+			return nil, fmt.Errorf("invalid bundle header line %q", line)
+		}
+		header.Refs[name] = sha
+	}
+}
+
+// openBundle opens a bundle file and reads its header
+func openBundle(path string) (*os.File, *bufio.Reader, *bundleHeader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	reader := bufio.NewReader(file)
+	header, err := readBundleHeader(reader)
+	if err != nil {
+		file.Close()
+		return nil, nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return file, reader, header, nil
+}
+
+// missingPrerequisites lists the prerequisites of a bundle repoRoot lacks
+func missingPrerequisites(repoRoot string, header *bundleHeader) []string {
+	var missing []string
+	for _, sha := range header.Prerequisites {
+		if !common.ObjectExists(repoRoot, sha) {
+			missing = append(missing, sha)
+		}
+	}
+	return missing
+}
+
+// bundleRevisions turns the arguments of bundle create into the refs to
+// include and the commits to leave out: "A..B" and "^A" exclude A, anything
+// else must name a ref
+func bundleRevisions(repoRoot string, args []string, all bool) (map[string]string, []string, error) {
+	refs := make(map[string]string)
+	var excludes []string
+	addRef := func(name string) error {
+		refName, err := common.ExpandRefName(repoRoot, name)
+		if err != nil {
+			return fmt.Errorf("%s is not a ref, a bundle can only carry refs", name)
+		}
+		sha, err := common.ResolveRevision(repoRoot, refName)
+		if err != nil {
+			return err
+		}
+		refs[refName] = sha
+		return nil
+	}
+	if all {
+		allRefs, err := common.ListRefs(repoRoot)
+		if err != nil {
+			return nil, nil, err
+		}
+		for name, sha := range allRefs {
+			if strings.HasPrefix(name, "refs/heads/") {
+				refs[name] = sha
+			}
+		}
+		if sha, err := common.GetParentSha(repoRoot); err == nil && sha != "" {
+			refs[common.HEAD] = sha
+		}
+	}
+	for _, arg := range args {
+		if exclude, include, ok := strings.Cut(arg, ".."); ok {
+			if include == "" {
+				include = common.HEAD
+			}
+			sha, err := common.ResolveCommit(repoRoot, exclude)
+			if err != nil {
+				return nil, nil, err
+			}
+			excludes = append(excludes, sha)
+			if err := addRef(include); err != nil {
+				return nil, nil, err
+			}
+		} else if exclude, ok := strings.CutPrefix(arg, "^"); ok {
+			sha, err := common.ResolveCommit(repoRoot, exclude)
+			if err != nil {
+				return nil, nil, err
+			}
+			excludes = append(excludes, sha)
+		} else if err := addRef(arg); err != nil {
+			return nil, nil, err
+		}
+	}
+	return refs, excludes, nil
+}
+
+// createBundle writes the objects the refs need, minus the history of
+// excludes, to path. The excluded commits the included ones build on become
+// the prerequisites.
+func createBundle(repoRoot string, path string, refs map[string]string, excludes []string) (*bundleHeader, int, error) {
+	excluded := make(map[string]bool)
+	for _, sha := range excludes {
+		ancestors, err := ancestorSet(repoRoot, sha)
+		if err != nil {
+			return nil, 0, err
+		}
+		for ancestor := range ancestors {
+			excluded[ancestor] = true
+		}
+	}
+	header := &bundleHeader{Refs: refs}
+	prerequisites := make(map[string]bool)
+	var wants []string
+	included := 0
+	for _, sha := range refs {
+		// a tag ref is carried as the tag object, its history is the one of the commit it tags
+		tip, err := common.PeelTo(repoRoot, sha, common.CommitFile)
+		if err != nil {
+			return nil, 0, err
+		}
+		if excluded[tip] && !prerequisites[tip] { // the ref itself is left out, the reader must have it
+			prerequisites[tip] = true
+			header.Prerequisites = append(header.Prerequisites, tip)
+		}
+		commits, err := commitsExcluding(repoRoot, excluded, tip)
+		if err != nil {
+			return nil, 0, err
+		}
+		included += len(commits)
+		for _, commitSha := range commits {
+			commit, err := common.ReadCommit(repoRoot, commitSha)
+			if err != nil {
+				return nil, 0, err
+			}
+			for _, parent := range commit.Parents {
+				if excluded[parent] && !prerequisites[parent] {
+					prerequisites[parent] = true
+					header.Prerequisites = append(header.Prerequisites, parent)
+				}
+			}
+		}
+		wants = append(wants, sha)
+	}
+	if included == 0 {
+		return nil, 0, fmt.Errorf("refusing to create an empty bundle")
+	}
+	sort.Strings(header.Prerequisites)
+	known, err := reachableObjects(repoRoot, header.Prerequisites)
+	if err != nil {
+		return nil, 0, err
+	}
+	shas, err := objectsToSend(repoRoot, wants, func(sha string) bool { return known[sha] })
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// written next to path and renamed, so a failure never leaves a truncated bundle
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	writer := bufio.NewWriter(file)
+	err = writeBundleHeader(writer, header)
+	if err == nil {
+		err = writeObjectStream(writer, repoRoot, shas)
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = file.Chmod(0644)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return nil, 0, err
+	}
+	return header, len(shas), nil
+}
+
+// verifyBundle checks every object in the bundle and that each ref is either
+// in it or reachable from the prerequisites, which repoRoot must have
+func verifyBundle(repoRoot string, path string) (*bundleHeader, error) {
+	file, reader, header, err := openBundle(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if missing := missingPrerequisites(repoRoot, header); len(missing) > 0 {
+		return header, fmt.Errorf("repository lacks these prerequisite commits:\n%s", strings.Join(missing, "\n"))
+	}
+	contained := make(map[string]bool)
+	err = scanObjectStream(reader, func(sha string, objType string, size int64, content io.Reader) error {
+		contained[sha] = true
+		return nil
+	})
+	if err != nil {
+		return header, err
+	}
+	for name, sha := range header.Refs {
+		if !contained[sha] && !common.ObjectExists(repoRoot, sha) {
+			return header, fmt.Errorf("%s points to %s, which is not in the bundle", name, sha)
+		}
+	}
+	return header, nil
+}
+
+func bundleCreate(repoRoot string, args []string, all bool) {
+	if len(args) < 1 || (len(args) < 2 && !all) {
+		log.Fatal("usage: mini-git bundle create <file> (--all | <rev-range>...)")
+	}
+	refs, excludes, err := bundleRevisions(repoRoot, args[1:], all)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(refs) == 0 {
+		log.Fatal("refusing to create an empty bundle")
+	}
+	header, count, err := createBundle(repoRoot, args[0], refs, excludes)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Wrote %d objects and %d refs to %s", count, len(header.Refs), args[0])
+	if len(header.Prerequisites) > 0 {
+		fmt.Printf(", requiring %d commits", len(header.Prerequisites))
+	}
+	fmt.Println()
+}
+
+func bundleVerify(repoRoot string, args []string) {
+	if len(args) != 1 {
+		log.Fatal("usage: mini-git bundle verify <file>")
+	}
+	header, err := verifyBundle(repoRoot, args[0])
+	if header != nil {
+		names := make([]string, 0, len(header.Refs))
+		for name := range header.Refs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Printf("The bundle contains %d refs:\n", len(names))
+		for _, name := range names {
+			fmt.Printf("%s %s\n", header.Refs[name], name)
+		}
+		if len(header.Prerequisites) == 0 {
+			fmt.Println("The bundle records a complete history.")
+		} else {
+			fmt.Printf("The bundle requires these %d commits:\n", len(header.Prerequisites))
+			for _, sha := range header.Prerequisites {
+				fmt.Println(sha)
+			}
+		}
+	}
+	if err != nil {
+		log.Fatalf("error: %v", err)
+	}
+	fmt.Printf("%s is okay\n", args[0])
+}
+
+func BundleCommand(cmd *cobra.Command, args []string) {
+	repoPath, err := common.FindRepoRoot()
+	if err != nil {
+		log.Fatal(err)
+	}
+	all, _ := cmd.Flags().GetBool("all")
+	switch args[0] {
+	case "create":
+		bundleCreate(repoPath, args[1:], all)
+	case "verify":
+		bundleVerify(repoPath, args[1:])
+	default:
+		log.Fatalf("unknown bundle subcommand %q, use create or verify", args[0])
+	}
+}
@@ -0,0 +1,48 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/hanzala211/mini-git/common"
+	"github.com/spf13/cobra"
+)
+
+func CatFileCommand(cmd *cobra.Command, args []string) {
+	repoPath, err := common.FindRepoRoot()
+	if err != nil {
+		log.Fatal(err)
+	}
+	showType, _ := cmd.Flags().GetBool("type")
+	showSize, _ := cmd.Flags().GetBool("size")
+	pretty, _ := cmd.Flags().GetBool("pretty")
+	modes := 0
+	for _, set := range []bool{showType, showSize, pretty} {
+		if set {
+			modes++
+		}
+	}
+	if modes != 1 {
+		log.Fatal("exactly one of -t, -s or -p is required")
+	}
+
+	sha, err := common.ResolveRevision(repoPath, args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	objType, content, err := common.ReadObjectWithType(repoPath, sha)
+	if err != nil {
+		log.Fatal(err)
+	}
+	switch {
+	case showType:
+		fmt.Println(objType)
+	case showSize:
+		fmt.Println(len(content))
+	case objType == common.TreeFile:
+		listTree(repoPath, sha, "", false)
+	default:
+		os.Stdout.Write(content)
+	}
+}
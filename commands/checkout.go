@@ -3,7 +3,6 @@ package commands
 import (
 	"bytes"
 	"encoding/hex"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
@@ -39,6 +38,9 @@ func parseTreeToMap(treeData []byte) (map[string]TreeEntry, error) {
 		fileName := string(treeData[fileNamStart:fullNullIndex]) // get the file name
 		shaStart := fullNullIndex + 1
 		shaEnd := shaStart + 20
+		if shaEnd > len(treeData) {
+			return nil, errors.New("invalid tree data")
+		}
 		sha := treeData[shaStart:shaEnd] // get the sha
 		entries[fileName] = TreeEntry{
 			Mode: mode,
@@ -49,17 +51,15 @@ func parseTreeToMap(treeData []byte) (map[string]TreeEntry, error) {
 	return entries, nil
 }
 
-func restoreFile(repoRoot string, blobSha string, filePath string) {
-	blobData, err := common.ReadObject(repoRoot, blobSha)
-	if err != nil {
-		log.Fatalf("failed to read object in restoreFile: %v", err)
-	}
-	if err := os.WriteFile(filePath, blobData, 0644); err != nil {
-		log.Fatalf("failed to write file: %v", err)
+// restoreFile streams the blob into place, checkout and fast-forward merges
+// never hold a whole file in memory
+func restoreFile(repoRoot string, lfs *lfsTracker, blobSha string, filePath string) {
+	if err := writeBlobToWorktree(repoRoot, lfs, blobSha, filePath); err != nil {
+		log.Fatal(err)
 	}
 }
 
-func restoreFullTree(repoRoot string, treeSha string, currentPath string) {
+func restoreFullTree(repoRoot string, lfs *lfsTracker, treeSha string, currentPath string) {
 	treeData, err := common.ReadObject(repoRoot, treeSha)
 	if err != nil {
 		log.Fatalf("failed to read object in restoreFullTree: %v", err)
@@ -87,9 +87,9 @@ func restoreFullTree(repoRoot string, treeSha string, currentPath string) {
 			if err := os.MkdirAll(filepath.Join(currentPath, fileName), 0755); err != nil {
 				log.Fatalf("failed to create directory: %v", err)
 			}
-			restoreFullTree(repoRoot, hex.EncodeToString(sha), filepath.Join(currentPath, fileName))
+			restoreFullTree(repoRoot, lfs, hex.EncodeToString(sha), filepath.Join(currentPath, fileName))
 		} else {
-			restoreFile(repoRoot, hex.EncodeToString(sha), filepath.Join(currentPath, fileName))
+			restoreFile(repoRoot, lfs, hex.EncodeToString(sha), filepath.Join(currentPath, fileName))
 		}
 	}
 }
@@ -117,6 +117,10 @@ func diffAndApply(repoRoot string, newTreeSha string, oldTreeSha string) {
 	if err != nil {
 		log.Fatalf("failed to parse tree: %v", err)
 	}
+	lfs, err := treeLFSTracker(repoRoot, newTreeSha)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	for filename, _ := range oldEntries {
 		if _, exists := newEntries[filename]; !exists {
@@ -138,18 +142,18 @@ func diffAndApply(repoRoot string, newTreeSha string, oldTreeSha string) {
 				log.Fatalf("failed to remove file: %v", err)
 			}
 			if newEntry.Mode == "040000" {
-				restoreFullTree(repoRoot, newEntry.SHA, fullPath)
+				restoreFullTree(repoRoot, lfs, newEntry.SHA, fullPath)
 			} else {
-				restoreFile(repoRoot, newEntry.SHA, fullPath)
+				restoreFile(repoRoot, lfs, newEntry.SHA, fullPath)
 			}
 		} else {
 			if newEntry.Mode == "040000" {
 				if err := os.MkdirAll(fullPath, 0755); err != nil {
 					log.Fatalf("failed to create directory: %v", err)
 				}
-				restoreFullTree(repoRoot, newEntry.SHA, fullPath)
+				restoreFullTree(repoRoot, lfs, newEntry.SHA, fullPath)
 			} else {
-				restoreFile(repoRoot, newEntry.SHA, fullPath)
+				restoreFile(repoRoot, lfs, newEntry.SHA, fullPath)
 			}
 		}
 	}
@@ -157,15 +161,13 @@ func diffAndApply(repoRoot string, newTreeSha string, oldTreeSha string) {
 	fmt.Println(oldEntries, newEntries)
 }
 
-func switchBranch(repoRoot string, newBranchPath string, currentBranch string) {
-	content, err := os.ReadFile(newBranchPath)
+func switchBranch(repoRoot string, newBranch string, currentBranch string) {
+	newBranchRef := "refs/heads/" + newBranch
+	contentStr, err := common.ReadRef(repoRoot, newBranchRef)
 	if err != nil {
 		log.Fatalf("failed to read branch: %v", err)
 	}
-	contentStr := string(content)
-	contentStr = strings.TrimSpace(contentStr)
-	currentBranchContent, err := os.ReadFile(filepath.Join(repoRoot, common.RootDir, common.RefsDir, common.HeadDir, currentBranch))
-	currentBranchContentStr := strings.TrimSpace(string(currentBranchContent))
+	currentBranchContentStr, err := common.GetParentSha(repoRoot) // also works with a detached HEAD
 	if currentBranchContentStr == contentStr {
 		return
 	}
@@ -173,7 +175,11 @@ func switchBranch(repoRoot string, newBranchPath string, currentBranch string) {
 		if err != nil {
 			log.Fatalf("failed to read current branch: %v", err)
 		}
-		os.WriteFile(newBranchPath, currentBranchContent, 0644)
+		transaction := common.NewRefTransaction(repoRoot)
+		transaction.Update(newBranchRef, currentBranchContentStr, "", "branch: Created from "+currentBranch)
+		if err := transaction.Commit(); err != nil {
+			log.Fatalf("failed to update branch: %v", err)
+		}
 		return
 	}
 	newCommitData, err := common.ReadObject(repoRoot, contentStr)
@@ -183,7 +189,7 @@ func switchBranch(repoRoot string, newBranchPath string, currentBranch string) {
 	newCommitDataStr := string(newCommitData)
 	newCommitDataStr = strings.TrimSpace(newCommitDataStr)
 	if newCommitDataStr == "" {
-		log.Fatalf("branch %s is not a valid branch", newBranchPath)
+		log.Fatalf("branch %s is not a valid branch", newBranch)
 	}
 	newTreeSha := strings.Split(newCommitDataStr, "\n")[0]
 	newTreeSha = strings.Split(newTreeSha, " ")[1]
@@ -195,7 +201,7 @@ func switchBranch(repoRoot string, newBranchPath string, currentBranch string) {
 	oldCommitDataStr := string(oldCommitData)
 	oldCommitDataStr = strings.TrimSpace(oldCommitDataStr)
 	if oldCommitDataStr == "" {
-		log.Fatalf("branch %s is not a valid branch", newBranchPath)
+		log.Fatalf("branch %s is not a valid branch", newBranch)
 	}
 	oldTreeSha := strings.Split(oldCommitDataStr, "\n")[0]
 	oldTreeSha = strings.Split(oldTreeSha, " ")[1]
@@ -263,24 +269,28 @@ func CheckoutCommand(cmd *cobra.Command, args []string) {
 	if err != nil {
 		log.Fatal(err)
 	}
-	headRef, _ := common.GetHeadRef(repoPath)
-	currentBranch := strings.Split(headRef, "/")[2]
+	currentBranch := currentBranchName(repoPath)
 	branchName := args[0]
 	if currentBranch == branchName {
 		fmt.Printf("Already on '%s'\n", branchName)
 		return
 	}
-	branchPath := filepath.Join(repoPath, common.RootDir, common.RefsDir, common.HeadDir, branchName)
-	if _, err := os.Stat(branchPath); err != nil {
+	if _, err := common.ReadRef(repoPath, "refs/heads/"+branchName); err != nil {
 		log.Fatalf("branch %s does not exist", branchName)
 	}
-	switchBranch(repoPath, branchPath, currentBranch)
+	if err := ensureBranchFree(repoPath, "refs/heads/"+branchName); err != nil {
+		log.Fatal(err)
+	}
+	previousSha, err := common.GetParentSha(repoPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	switchBranch(repoPath, branchName, currentBranch)
 
-	branchContent, err := os.ReadFile(branchPath)
+	branchCommitSha, err := common.ReadRef(repoPath, "refs/heads/"+branchName)
 	if err != nil {
 		log.Fatalf("failed to read branch: %v", err)
 	}
-	branchCommitSha := strings.TrimSpace(string(branchContent))
 	if branchCommitSha != "" {
 		commitData, err := common.ReadObject(repoPath, branchCommitSha)
 		if err != nil {
@@ -296,30 +306,29 @@ func CheckoutCommand(cmd *cobra.Command, args []string) {
 			log.Fatalf("failed to build index from tree: %v", err)
 		}
 
-		indexBytes, err := json.MarshalIndent(newIndex, "", "  ")
-		if err != nil {
-			log.Fatalf("failed to marshal index: %v", err)
-		}
-		indexPath := filepath.Join(repoPath, common.RootDir, common.IndexFile)
-		if err := os.WriteFile(indexPath, indexBytes, 0644); err != nil {
+		if err := common.WriteIndex(repoPath, newIndex); err != nil {
 			log.Fatalf("failed to write index: %v", err)
 		}
 	} else {
 		// Empty branch - clear the index
 		emptyIndex := make(common.Index)
-		indexBytes, err := json.MarshalIndent(emptyIndex, "", "  ")
-		if err != nil {
-			log.Fatalf("failed to marshal index: %v", err)
-		}
-		indexPath := filepath.Join(repoPath, common.RootDir, common.IndexFile)
-		if err := os.WriteFile(indexPath, indexBytes, 0644); err != nil {
+		if err := common.WriteIndex(repoPath, emptyIndex); err != nil {
 			log.Fatalf("failed to write index: %v", err)
 		}
 	}
 
-	err = os.WriteFile(filepath.Join(repoPath, common.RootDir, common.HEAD), []byte("ref: refs/heads/"+branchName), 0644)
+	reason := fmt.Sprintf("checkout: moving from %s to %s", currentBranch, branchName)
+	err = common.SetHeadRef(repoPath, "refs/heads/"+branchName, reason)
 	if err != nil {
 		log.Fatalf("failed to update head: %v", err)
 	}
 	fmt.Printf("Switched to branch %s\n", branchName)
+	newSha, err := common.GetParentSha(repoPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// the last argument is 1 for a branch checkout, the checkout is done so a failure only shows in the exit status
+	if err := runHook(repoPath, "post-checkout", hookSha(previousSha), hookSha(newSha), "1"); err != nil {
+		log.Fatal(err)
+	}
 }
@@ -0,0 +1,106 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hanzala211/mini-git/common"
+	"github.com/spf13/cobra"
+)
+
+const defaultRemote = "origin"
+
+// cloneCheckout puts the clone's HEAD on the branch the source has checked out
+// and fills the working tree and index from it
+func cloneCheckout(repoRoot string, headRef string, headSha string, reason string) error {
+	// init left an empty master behind, the branch it is replaced by is created below
+	if err := common.DeleteRef(repoRoot, "refs/heads/master"); err != nil {
+		return err
+	}
+	if strings.HasPrefix(headRef, "refs/heads/") {
+		// HEAD goes on the branch first so creating it logs to both reflogs once
+		if err := common.SetHeadRef(repoRoot, headRef, reason); err != nil {
+			return err
+		}
+		if err := common.UpdateRef(repoRoot, headRef, headSha, reason); err != nil {
+			return err
+		}
+	} else if err := common.SetHeadDetached(repoRoot, headSha, reason); err != nil {
+		return err
+	}
+	target, err := commitIndex(repoRoot, headSha)
+	if err != nil {
+		return err
+	}
+	if err := updateWorktree(repoRoot, make(common.Index), target); err != nil {
+		return err
+	}
+	return common.WriteIndex(repoRoot, target)
+}
+
+func CloneCommand(cmd *cobra.Command, args []string) {
+	url, err := normalizeRemoteURL(args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	transport, err := openTransport(url)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer transport.Close()
+	dir := strings.TrimSuffix(filepath.Base(url), ".bundle")
+	if len(args) > 1 {
+		dir = args[1]
+	}
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		log.Fatalf("destination path '%s' already exists and is not an empty directory", dir)
+	}
+	dstRoot, err := filepath.Abs(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.MkdirAll(dstRoot, 0755); err != nil {
+		log.Fatal(err)
+	}
+	if err := initRepository(dstRoot); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Cloning into '%s'...\n", dir)
+
+	config, err := common.ReadConfig(dstRoot)
+	if err != nil {
+		log.Fatal(err)
+	}
+	config.Remotes[defaultRemote] = common.RemoteConfig{URL: url}
+	ads, _, err := fetchRemote(dstRoot, defaultRemote, transport, false)
+	if err != nil {
+		log.Fatal(err)
+	}
+	headSha := ads.Refs[common.HEAD]
+	if headSha == "" {
+		fmt.Println("warning: You appear to have cloned an empty repository.")
+	} else {
+		if local, ok := transport.(*localTransport); ok {
+			// only the large files of the checkout, other versions are left to "lfs fetch"
+			pointers, err := commitPointers(dstRoot, headSha)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if _, err := fetchLFSObjects(dstRoot, local.root, pointers); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if err := cloneCheckout(dstRoot, ads.Head, headSha, "clone: from "+url); err != nil {
+			log.Fatal(err)
+		}
+		if branch, ok := strings.CutPrefix(ads.Head, "refs/heads/"); ok {
+			config.Branches[branch] = common.BranchConfig{Remote: defaultRemote, Merge: ads.Head}
+		}
+	}
+	if err := common.WriteConfig(dstRoot, config); err != nil {
+		log.Fatal(err)
+	}
+}
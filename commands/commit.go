@@ -101,11 +101,28 @@ func CommitCommand(cmd *cobra.Command, args []string) {
 		log.Fatal(err)
 	}
 	commitMsg, _ := cmd.Flags().GetString("m")
+	if fixup, _ := cmd.Flags().GetString("fixup"); fixup != "" {
+		targetSha, err := common.ResolveCommit(repoPath, fixup)
+		if err != nil {
+			log.Fatal(err)
+		}
+		target, err := common.ReadCommit(repoPath, targetSha)
+		if err != nil {
+			log.Fatal(err)
+		}
+		commitMsg = "fixup! " + firstLine(target.Message)
+	}
 	if commitMsg == "" {
 		log.Fatal("commit message is required")
 	}
+	noVerify, _ := cmd.Flags().GetBool("no-verify")
+	if !noVerify { // before the index is read, so a hook can still stage changes
+		if err := runHook(repoPath, "pre-commit"); err != nil {
+			log.Fatalf("%v, aborting commit", err)
+		}
+	}
 
-	indexBytes, err := os.ReadFile(filepath.Join(repoPath, common.RootDir, common.IndexFile))
+	indexBytes, err := os.ReadFile(filepath.Join(common.MinigitDir(repoPath), common.IndexFile))
 	if err != nil {
 		log.Fatal("Failed to read index")
 	}
@@ -124,39 +141,50 @@ func CommitCommand(cmd *cobra.Command, args []string) {
 
 	treeSha, err := buildTree(repoPath, index)
 	if err != nil {
-		log.Fatalf("failed to build trees: %w", err)
+		log.Fatalf("failed to build trees: %v", err)
 	}
 	lastCommitTreeSha, err := findLastCommitTreeSha(repoPath)
 	if lastCommitTreeSha == treeSha {
 		log.Fatal("no file to push to commit")
 	}
 	if err != nil {
-		log.Fatalf("failed to find last commit tree: %w", err)
+		log.Fatalf("failed to find last commit tree: %v", err)
 	}
 
 	parentSha, err := common.GetParentSha(repoPath)
 	if err != nil {
-		log.Fatalf("failed to get parent commit: %w", err)
+		log.Fatalf("failed to get parent commit: %v", err)
+	}
+	if !noVerify {
+		if commitMsg, err = runCommitMsgHook(repoPath, commitMsg); err != nil {
+			log.Fatalf("%v, aborting commit", err)
+		}
 	}
 
-	now := time.Now()
-	timestamp := fmt.Sprintf("%d %s", now.Unix(), now.Format("-0700"))
-
-	var commitContent bytes.Buffer
-
-	fmt.Fprintf(&commitContent, "tree %s\n", treeSha)
+	commit := &common.Commit{
+		Tree:      treeSha,
+		Message:   commitMsg,
+		Timestamp: common.Timestamp(time.Now()),
+	}
 	if parentSha != "" {
-		fmt.Fprintf(&commitContent, "parent %s\n", parentSha)
+		commit.Parents = []string{parentSha}
+	}
+	sign, err := wantSignature(repoPath, cmd, "gpg-sign")
+	if err != nil {
+		log.Fatal(err)
 	}
-	fmt.Fprintf(&commitContent, "\n%s\n", commitMsg)
-	fmt.Fprintf(&commitContent, "%s\n", timestamp)
-	commitSha, err := common.WriteObject(repoPath, commitContent.Bytes(), common.CommitFile, "")
+	commitSha, err := writeCommit(repoPath, commit, sign)
 	if err != nil {
-		log.Fatalf("failed to write commit object: %w", err)
+		log.Fatal(err)
+	}
+	reason := "commit: "
+	if parentSha == "" {
+		reason = "commit (initial): "
 	}
-	err = common.UpdateHead(repoPath, commitSha)
+	err = common.UpdateHead(repoPath, commitSha, parentSha, reason+strings.Split(commitMsg, "\n")[0])
 	if err != nil {
-		log.Fatalf("failed to update head: %w", err)
+		log.Fatalf("failed to update head: %v", err)
 	}
 	fmt.Println("changes committed")
+	runHook(repoPath, "post-commit") // too late to undo anything, its status is ignored
 }
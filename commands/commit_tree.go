@@ -0,0 +1,64 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hanzala211/mini-git/common"
+	"github.com/spf13/cobra"
+)
+
+func CommitTreeCommand(cmd *cobra.Command, args []string) {
+	repoPath, err := common.FindRepoRoot()
+	if err != nil {
+		log.Fatal(err)
+	}
+	parents, _ := cmd.Flags().GetStringArray("parent")
+	message, _ := cmd.Flags().GetString("message")
+	if message == "" { // like git, read the message from stdin when -m is not given
+		stdinMsg, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatalf("failed to read message from stdin: %v", err)
+		}
+		message = strings.TrimRight(string(stdinMsg), "\n")
+	}
+	if message == "" {
+		log.Fatal("commit message is required")
+	}
+
+	treeSha, err := common.ResolveRevision(repoPath, args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if objType, _, err := common.ReadObjectWithType(repoPath, treeSha); err != nil || objType != common.TreeFile {
+		log.Fatalf("%s is not a valid tree object", args[0])
+	}
+	commit := &common.Commit{
+		Tree:      treeSha,
+		Message:   message,
+		Timestamp: common.Timestamp(time.Now()),
+	}
+	for _, parent := range parents {
+		parentSha, err := common.ResolveCommit(repoPath, parent)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if _, err := common.ReadCommit(repoPath, parentSha); err != nil {
+			log.Fatalf("%s is not a valid commit: %v", parent, err)
+		}
+		commit.Parents = append(commit.Parents, parentSha)
+	}
+	sign, err := wantSignature(repoPath, cmd, "gpg-sign")
+	if err != nil {
+		log.Fatal(err)
+	}
+	commitSha, err := writeCommit(repoPath, commit, sign)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(commitSha)
+}
@@ -0,0 +1,235 @@
+package commands
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hanzala211/mini-git/common"
+)
+
+type diffOp int
+
+const (
+	diffEqual diffOp = iota
+	diffDelete
+	diffInsert
+)
+
+// diffEdit is one step of an edit script turning a into b, for equal and
+// delete steps aIndex is the line in a, for equal and insert steps bIndex is the line in b
+type diffEdit struct {
+	op     diffOp
+	aIndex int
+	bIndex int
+}
+
+// splitLines splits content into lines, keeping the trailing "\n" on each line
+func splitLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(content), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines computes the shortest edit script between a and b using Myers' algorithm
+func diffLines(a []string, b []string) []diffEdit {
+	n, m := len(a), len(b)
+	maxSteps := n + m
+	offset := maxSteps + 1
+	v := make([]int, 2*maxSteps+3)
+	var trace [][]int
+
+	found := false
+	for d := 0; d <= maxSteps && !found; d++ {
+		snapshot := make([]int, len(v))
+		copy(snapshot, v)
+		trace = append(trace, snapshot)
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1] // step down, an insert
+			} else {
+				x = v[offset+k-1] + 1 // step right, a delete
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+
+	// walk the trace backwards to recover the path
+	var edits []diffEdit
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, diffEdit{diffEqual, x, y})
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			y--
+			edits = append(edits, diffEdit{diffInsert, x, y})
+		} else {
+			x--
+			edits = append(edits, diffEdit{diffDelete, x, y})
+		}
+	}
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+// matchLines returns for every line of a the index of the line in b it is matched with, or -1
+func matchLines(a []string, b []string) []int {
+	matches := make([]int, len(a))
+	for i := range matches {
+		matches[i] = -1
+	}
+	for _, edit := range diffLines(a, b) {
+		if edit.op == diffEqual {
+			matches[edit.aIndex] = edit.bIndex
+		}
+	}
+	return matches
+}
+
+// unifiedDiff renders a diff between two versions of a file with 3 lines of context
+func unifiedDiff(oldName string, newName string, oldContent []byte, newContent []byte) string {
+	const context = 3
+	a, b := splitLines(oldContent), splitLines(newContent)
+	edits := diffLines(a, b)
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+
+	for start := 0; start < len(edits); {
+		if edits[start].op == diffEqual {
+			start++
+			continue
+		}
+		// grow the hunk until there are more than 2*context equal lines in a row
+		hunkStart := max(start-context, 0)
+		end := start
+		for end < len(edits) {
+			if edits[end].op != diffEqual {
+				end++
+				continue
+			}
+			run := end
+			for run < len(edits) && edits[run].op == diffEqual {
+				run++
+			}
+			if run == len(edits) || run-end > 2*context {
+				end = min(end+context, len(edits))
+				break
+			}
+			end = run
+		}
+		oldStart, newStart, oldCount, newCount := -1, -1, 0, 0
+		var body strings.Builder
+		for _, edit := range edits[hunkStart:end] {
+			switch edit.op {
+			case diffEqual:
+				body.WriteString(" " + withNewline(a[edit.aIndex]))
+				oldCount++
+				newCount++
+			case diffDelete:
+				body.WriteString("-" + withNewline(a[edit.aIndex]))
+				oldCount++
+			case diffInsert:
+				body.WriteString("+" + withNewline(b[edit.bIndex]))
+				newCount++
+			}
+			if oldStart == -1 {
+				oldStart, newStart = edit.aIndex, edit.bIndex
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", oldStart+1, oldCount, newStart+1, newCount)
+		out.WriteString(body.String())
+		start = end
+	}
+	return out.String()
+}
+
+func withNewline(line string) string {
+	if strings.HasSuffix(line, "\n") {
+		return line
+	}
+	return line + "\n\\ No newline at end of file\n"
+}
+
+// printIndexDiff prints the paths that differ between two flattened trees,
+// either as "M path" style name-status lines or as unified diffs
+func printIndexDiff(repoRoot string, oldIndex common.Index, newIndex common.Index, patch bool) error {
+	paths := make(map[string]bool)
+	for path := range oldIndex {
+		paths[path] = true
+	}
+	for path := range newIndex {
+		paths[path] = true
+	}
+	sortedPaths := make([]string, 0, len(paths))
+	for path := range paths {
+		if oldIndex[path] != newIndex[path] {
+			sortedPaths = append(sortedPaths, path)
+		}
+	}
+	sort.Strings(sortedPaths)
+
+	for _, path := range sortedPaths {
+		oldSha, newSha := oldIndex[path], newIndex[path]
+		if !patch {
+			status := "M"
+			if oldSha == "" {
+				status = "A"
+			} else if newSha == "" {
+				status = "D"
+			}
+			fmt.Printf("%s\t%s\n", status, path)
+			continue
+		}
+		oldContent, err := readBlobOrEmpty(repoRoot, oldSha)
+		if err != nil {
+			return err
+		}
+		newContent, err := readBlobOrEmpty(repoRoot, newSha)
+		if err != nil {
+			return err
+		}
+		oldName, newName := "a/"+path, "b/"+path
+		if oldSha == "" {
+			oldName = "/dev/null"
+		}
+		if newSha == "" {
+			newName = "/dev/null"
+		}
+		fmt.Printf("diff --mini-git a/%s b/%s\n", path, path)
+		fmt.Print(unifiedDiff(oldName, newName, oldContent, newContent))
+	}
+	return nil
+}
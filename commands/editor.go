@@ -0,0 +1,69 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/hanzala211/mini-git/common"
+)
+
+const commitEditMsgFile = "COMMIT_EDITMSG"
+
+// editorCommand picks the editor like git does: MINIGIT_EDITOR, then EDITOR, then vi
+func editorCommand() string {
+	for _, name := range []string{"MINIGIT_EDITOR", "EDITOR"} {
+		if editor := os.Getenv(name); editor != "" {
+			return editor
+		}
+	}
+	return "vi"
+}
+
+// runEditor opens path in the editor and waits for it, the editor string may
+// carry arguments so it goes through the shell
+func runEditor(path string) error {
+	editor := editorCommand()
+	cmd := exec.Command("sh", "-c", editor+` "$@"`, editor, path)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("there was a problem with the editor %q: %w", editor, err)
+	}
+	return nil
+}
+
+// stripComments drops the lines starting with # and trims surrounding blank lines
+func stripComments(content string) string {
+	var lines []string
+	for _, line := range strings.Split(content, "\n") {
+		if !strings.HasPrefix(line, "#") {
+			lines = append(lines, strings.TrimRight(line, " \t\r"))
+		}
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+// editMessage lets the user edit a commit message, an empty result is an error
+func editMessage(repoRoot string, message string) (string, error) {
+	path := filepath.Join(common.MinigitDir(repoRoot), commitEditMsgFile)
+	content := message + "\n\n" +
+		"# Please enter the commit message for your changes. Lines starting\n" +
+		"# with '#' will be ignored, and an empty message aborts the commit.\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return "", err
+	}
+	if err := runEditor(path); err != nil {
+		return "", err
+	}
+	edited, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	message = stripComments(string(edited))
+	if message == "" {
+		return "", fmt.Errorf("aborting commit due to empty commit message")
+	}
+	return message, nil
+}
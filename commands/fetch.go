@@ -0,0 +1,134 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/hanzala211/mini-git/common"
+	"github.com/spf13/cobra"
+)
+
+// fetchedRef is one remote-tracking ref that a fetch moved, New is "" when it was pruned
+type fetchedRef struct {
+	Branch string
+	Old    string
+	New    string
+}
+
+// fetchRemote copies the remote's branches into refs/remotes/<remote>/*,
+// prune removes tracking refs whose branch is gone from the remote
+func fetchRemote(repoRoot string, remote string, transport Transport, prune bool) (*refAdvertisement, []fetchedRef, error) {
+	ads, err := transport.ListRefs()
+	if err != nil {
+		return nil, nil, err
+	}
+	localRefs, err := common.ListRefs(repoRoot)
+	if err != nil {
+		return nil, nil, err
+	}
+	var wants, haves []string
+	for _, sha := range ads.Refs {
+		if !common.ObjectExists(repoRoot, sha) {
+			wants = append(wants, sha)
+		}
+	}
+	for _, sha := range localRefs {
+		haves = append(haves, sha)
+	}
+	if len(wants) > 0 {
+		if _, err := transport.Fetch(repoRoot, wants, haves); err != nil {
+			return nil, nil, fmt.Errorf("failed to fetch objects: %w", err)
+		}
+	}
+
+	trackingPrefix := fmt.Sprintf("%s/%s/%s/", common.RefsDir, common.RemotesDir, remote)
+	var updated []fetchedRef
+	for name, sha := range ads.Refs {
+		branch, ok := strings.CutPrefix(name, "refs/heads/")
+		if !ok || localRefs[trackingPrefix+branch] == sha {
+			continue
+		}
+		old := localRefs[trackingPrefix+branch]
+		reason := fmt.Sprintf("fetch %s: storing head", remote)
+		if old != "" {
+			reason = "fetch: forced-update"
+			if isAncestor(repoRoot, old, sha) {
+				reason = "fetch: fast-forward"
+			}
+		}
+		if err := common.UpdateRef(repoRoot, trackingPrefix+branch, sha, reason); err != nil {
+			return nil, nil, err
+		}
+		updated = append(updated, fetchedRef{Branch: branch, Old: old, New: sha})
+	}
+	if prune {
+		for name, sha := range localRefs {
+			branch, ok := strings.CutPrefix(name, trackingPrefix)
+			if _, advertised := ads.Refs["refs/heads/"+branch]; !ok || advertised {
+				continue
+			}
+			if err := common.DeleteRef(repoRoot, name); err != nil {
+				return nil, nil, err
+			}
+			updated = append(updated, fetchedRef{Branch: branch, Old: sha})
+		}
+	}
+	sort.Slice(updated, func(i, j int) bool { return updated[i].Branch < updated[j].Branch })
+	return ads, updated, nil
+}
+
+// defaultRemoteName is the remote the current branch tracks, or origin
+func defaultRemoteName(repoRoot string, config *common.Config) string {
+	if branch, ok := config.Branches[currentBranchName(repoRoot)]; ok && branch.Remote != "" {
+		return branch.Remote
+	}
+	return defaultRemote
+}
+
+func FetchCommand(cmd *cobra.Command, args []string) {
+	repoPath, err := common.FindRepoRoot()
+	if err != nil {
+		log.Fatal(err)
+	}
+	config, err := common.ReadConfig(repoPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	remote := defaultRemoteName(repoPath, config)
+	if len(args) > 0 {
+		remote = args[0]
+	}
+	remoteConfig, ok := config.Remotes[remote]
+	if !ok {
+		log.Fatalf("no such remote '%s'", remote)
+	}
+	prune, _ := cmd.Flags().GetBool("prune")
+
+	transport, err := openTransport(remoteConfig.URL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer transport.Close()
+	_, updated, err := fetchRemote(repoPath, remote, transport, prune)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(updated) > 0 {
+		fmt.Printf("From %s\n", remoteConfig.URL)
+	}
+	for _, ref := range updated {
+		tracking := remote + "/" + ref.Branch
+		switch {
+		case ref.New == "":
+			fmt.Printf(" - %-17s %-10s -> %s\n", "[deleted]", "(none)", tracking)
+		case ref.Old == "":
+			fmt.Printf(" * %-17s %-10s -> %s\n", "[new branch]", ref.Branch, tracking)
+		case isAncestor(repoPath, ref.Old, ref.New):
+			fmt.Printf("   %-17s %-10s -> %s\n", ref.Old[:7]+".."+ref.New[:7], ref.Branch, tracking)
+		default:
+			fmt.Printf(" + %-17s %-10s -> %s  (forced update)\n", ref.Old[:7]+"..."+ref.New[:7], ref.Branch, tracking)
+		}
+	}
+}
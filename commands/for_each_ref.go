@@ -0,0 +1,156 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hanzala211/mini-git/common"
+	"github.com/spf13/cobra"
+)
+
+const DefaultRefFormat = "%(objectname) %(objecttype)\t%(refname)"
+
+var refFormatAtom = regexp.MustCompile(`%\(([^)]*)\)|%%`)
+
+var refFormatAtoms = map[string]bool{
+	"refname": true, "refname:short": true, "objectname": true, "objectname:short": true,
+	"objecttype": true, "subject": true, "author": true, "authordate": true, "HEAD": true,
+}
+
+// refMatches follows git: a pattern matches the ref itself, everything below
+// it when it names a directory like refs/heads, or the ref as a glob
+func refMatches(refName string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if refName == pattern || strings.HasPrefix(refName, strings.TrimSuffix(pattern, "/")+"/") {
+			return true
+		}
+		if matched, _ := path.Match(pattern, refName); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func shortRefName(refName string) string {
+	for _, prefix := range []string{"refs/heads/", "refs/tags/", "refs/remotes/", "refs/"} {
+		if short, ok := strings.CutPrefix(refName, prefix); ok {
+			return short
+		}
+	}
+	return refName
+}
+
+// refFields loads what the atoms of one ref need, the object is only read once
+type refFields struct {
+	name    string
+	sha     string
+	objType string
+	commit  *common.Commit
+	tag     *common.Tag
+	headRef string
+}
+
+func (f *refFields) atom(name string) string {
+	switch name {
+	case "refname":
+		return f.name
+	case "refname:short":
+		return shortRefName(f.name)
+	case "objectname":
+		return f.sha
+	case "objectname:short":
+		return f.sha[:7]
+	case "objecttype":
+		return f.objType
+	case "subject":
+		if f.commit != nil {
+			return firstLine(f.commit.Message)
+		}
+		if f.tag != nil {
+			return firstLine(f.tag.Message)
+		}
+	case "author":
+		if f.commit != nil {
+			return f.commit.AuthorName()
+		}
+	case "authordate":
+		if f.commit == nil {
+			return ""
+		}
+		if date, err := f.commit.AuthorTime(); err == nil {
+			return date.Format(logDateFormat)
+		}
+	case "HEAD":
+		if f.name == f.headRef {
+			return "*"
+		}
+		return " "
+	}
+	return ""
+}
+
+func loadRefFields(repoRoot string, name string, sha string, headRef string) (*refFields, error) {
+	objType, data, err := common.ReadObjectWithType(repoRoot, sha)
+	if err != nil {
+		return nil, fmt.Errorf("missing object %s for %s", sha, name)
+	}
+	fields := &refFields{name: name, sha: sha, objType: objType, headRef: headRef}
+	switch objType {
+	case common.CommitFile:
+		fields.commit, err = common.ParseCommit(data)
+	case common.TagFile:
+		fields.tag, err = common.ParseTag(data)
+	}
+	return fields, err
+}
+
+func ForEachRefCommand(cmd *cobra.Command, args []string) {
+	repoPath, err := common.FindRepoRoot()
+	if err != nil {
+		log.Fatal(err)
+	}
+	format, _ := cmd.Flags().GetString("format")
+	count, _ := cmd.Flags().GetInt("count")
+	for _, match := range refFormatAtom.FindAllStringSubmatch(format, -1) {
+		if match[0] != "%%" && !refFormatAtoms[match[1]] {
+			log.Fatalf("unknown field name: %s", match[1])
+		}
+	}
+	refs, err := common.ListRefs(repoPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	headRef, err := common.GetHeadRef(repoPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var names []string
+	for name := range refs {
+		if refMatches(name, args) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if count > 0 && len(names) > count {
+		names = names[:count]
+	}
+	for _, name := range names {
+		fields, err := loadRefFields(repoPath, name, refs[name], headRef)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(refFormatAtom.ReplaceAllStringFunc(format, func(atom string) string {
+			if atom == "%%" {
+				return "%"
+			}
+			return fields.atom(atom[2 : len(atom)-1])
+		}))
+	}
+}
@@ -0,0 +1,228 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hanzala211/mini-git/common"
+	"github.com/spf13/cobra"
+)
+
+type fsckObject struct {
+	objType string
+	links   []fsckLink // objects this one points to
+}
+
+type fsckLink struct {
+	sha     string
+	objType string // the type the pointing object expects
+}
+
+// checkObject rehashes a raw object and parses it, returning its type and outgoing links
+func checkObject(repoRoot string, sha string) (*fsckObject, error) {
+	raw, err := common.ReadRawObject(repoRoot, sha)
+	if err != nil {
+		return nil, err
+	}
+	objType, size, content, err := common.ParseObjectHeader(raw)
+	if err != nil {
+		return nil, err
+	}
+	if size != len(content) {
+		return nil, fmt.Errorf("header size %d does not match content size %d", size, len(content))
+	}
+	if actual := common.HashObject(content, objType); actual != sha {
+		return nil, fmt.Errorf("hash mismatch, content hashes to %s", actual)
+	}
+	links, err := objectLinks(objType, content)
+	if err != nil {
+		return nil, err
+	}
+	return &fsckObject{objType: objType, links: links}, nil
+}
+
+// objectLinks parses an object and returns the objects it points to
+func objectLinks(objType string, content []byte) ([]fsckLink, error) {
+	var links []fsckLink
+	switch objType {
+	case common.BlobFile:
+	case common.CommitFile:
+		commit, err := common.ParseCommit(content)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, fsckLink{commit.Tree, common.TreeFile})
+		for _, parent := range commit.Parents {
+			links = append(links, fsckLink{parent, common.CommitFile})
+		}
+	case common.TagFile:
+		tag, err := common.ParseTag(content)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, fsckLink{tag.Object, tag.Type})
+	case common.TreeFile:
+		entries, err := parseTreeToMap(content)
+		if err != nil {
+			return nil, err
+		}
+		for name, entry := range entries {
+			if entry.Mode != "040000" && entry.Mode != "100644" && entry.Mode != "100755" {
+				return nil, fmt.Errorf("entry %s has unknown mode %s", name, entry.Mode)
+			}
+			links = append(links, fsckLink{entry.SHA, modeToType(entry.Mode)})
+		}
+	default:
+		return nil, fmt.Errorf("unknown object type %q", objType)
+	}
+	return links, nil
+}
+
+// reachabilityRoots returns every sha fsck and gc treat as reachable by definition:
+// refs, HEAD, the index and every sha still mentioned in a reflog, in every worktree
+func reachabilityRoots(repoRoot string) ([]fsckLink, error) {
+	worktrees, err := common.ListWorktrees(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	var roots []fsckLink
+	for _, worktree := range worktrees {
+		if worktree.Prunable() != "" { // only its HEAD is left until worktree prune removes it
+			if _, sha, err := worktree.Head(repoRoot); err == nil && sha != "" {
+				roots = append(roots, fsckLink{sha, common.CommitFile})
+			}
+			continue
+		}
+		worktreeRoots, err := worktreeReachabilityRoots(worktree.Path)
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, worktreeRoots...)
+	}
+	return roots, nil
+}
+
+// worktreeReachabilityRoots returns the refs, HEAD, index and reflogs as seen from one worktree
+func worktreeReachabilityRoots(repoRoot string) ([]fsckLink, error) {
+	var roots []fsckLink
+	refs, err := common.ListRefs(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	for name, sha := range refs {
+		expected := common.CommitFile
+		if strings.HasPrefix(name, common.RefsDir+"/"+common.TagsDir+"/") {
+			expected = "" // a tag ref holds a tag object or, for a lightweight tag, a commit
+		}
+		roots = append(roots, fsckLink{sha, expected})
+	}
+	if headSha, err := common.GetParentSha(repoRoot); err == nil && headSha != "" {
+		roots = append(roots, fsckLink{headSha, common.CommitFile})
+	}
+	index, err := common.ReadIndex(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	for _, sha := range index {
+		roots = append(roots, fsckLink{sha, common.BlobFile})
+	}
+	refNames, err := common.ListReflogs(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	for _, refName := range refNames {
+		entries, err := common.ReadReflog(repoRoot, refName)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			for _, sha := range []string{entry.OldSha, entry.NewSha} {
+				if sha != common.ZeroSha {
+					roots = append(roots, fsckLink{sha, common.CommitFile})
+				}
+			}
+		}
+	}
+	return roots, nil
+}
+
+func FsckCommand(cmd *cobra.Command, args []string) {
+	repoPath, err := common.FindRepoRoot()
+	if err != nil {
+		log.Fatal(err)
+	}
+	noDangling, _ := cmd.Flags().GetBool("no-dangling")
+
+	shas, err := common.ListObjects(repoPath)
+	if err != nil {
+		log.Fatalf("failed to list objects: %v", err)
+	}
+	sort.Strings(shas)
+	objects := make(map[string]*fsckObject)
+	corrupt := make(map[string]bool)
+	problems := 0
+	for _, sha := range shas {
+		object, err := checkObject(repoPath, sha)
+		if err != nil {
+			fmt.Printf("corrupt object %s: %v\n", sha, err)
+			corrupt[sha] = true
+			problems++
+			continue
+		}
+		objects[sha] = object
+	}
+
+	roots, err := reachabilityRoots(repoPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	reachable := make(map[string]bool)
+	missing := make(map[string]bool)
+	queue := roots
+	for len(queue) > 0 {
+		link := queue[0]
+		queue = queue[1:]
+		if reachable[link.sha] || missing[link.sha] {
+			continue
+		}
+		object, ok := objects[link.sha]
+		if !ok {
+			if !corrupt[link.sha] {
+				expected := link.objType
+				if expected == "" {
+					expected = "object"
+				}
+				fmt.Printf("missing %s %s\n", expected, link.sha)
+				problems++
+			}
+			missing[link.sha] = true
+			continue
+		}
+		if link.objType != "" && object.objType != link.objType {
+			fmt.Printf("error: %s is a %s, expected %s\n", link.sha, object.objType, link.objType)
+			problems++
+		}
+		reachable[link.sha] = true
+		queue = append(queue, object.links...)
+	}
+
+	if !noDangling {
+		referenced := make(map[string]bool)
+		for _, object := range objects {
+			for _, link := range object.links {
+				referenced[link.sha] = true
+			}
+		}
+		for _, sha := range shas {
+			if object, ok := objects[sha]; ok && !reachable[sha] && !referenced[sha] {
+				fmt.Printf("dangling %s %s\n", object.objType, sha)
+			}
+		}
+	}
+	if problems > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,149 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/hanzala211/mini-git/common"
+	"github.com/spf13/cobra"
+)
+
+const DefaultPruneExpiry = "2.weeks.ago"
+
+var relativeAge = regexp.MustCompile(`^(\d+)[. ]?(second|minute|hour|day|week|month|year)s?[. ]ago$`)
+
+var ageUnits = map[string]time.Duration{
+	"second": time.Second,
+	"minute": time.Minute,
+	"hour":   time.Hour,
+	"day":    24 * time.Hour,
+	"week":   7 * 24 * time.Hour,
+	"month":  30 * 24 * time.Hour,
+	"year":   365 * 24 * time.Hour,
+}
+
+// parseExpiry turns values like "now", "never", "2.weeks.ago", "36h" or "2024-01-31"
+// into a cutoff time, objects modified before the cutoff can be pruned
+func parseExpiry(value string, now time.Time) (time.Time, error) {
+	switch value {
+	case "now", "all":
+		return now, nil
+	case "never":
+		return time.Time{}, nil
+	}
+	if match := relativeAge.FindStringSubmatch(value); match != nil {
+		n, _ := strconv.Atoi(match[1])
+		return now.Add(-time.Duration(n) * ageUnits[match[2]]), nil
+	}
+	if duration, err := time.ParseDuration(value); err == nil {
+		return now.Add(-duration), nil
+	}
+	if date, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return date, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid expiry %q", value)
+}
+
+// markReachable walks every object reachable from the refs, HEAD and the index
+func markReachable(repoRoot string) (map[string]bool, error) {
+	roots, err := reachabilityRoots(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	reachable := make(map[string]bool)
+	queue := roots
+	for len(queue) > 0 {
+		link := queue[0]
+		queue = queue[1:]
+		if reachable[link.sha] {
+			continue
+		}
+		reachable[link.sha] = true
+		objType, content, err := common.ReadObjectWithType(repoRoot, link.sha)
+		if err != nil {
+			continue // missing objects are fsck's problem, they have nothing to keep alive
+		}
+		links, err := objectLinks(objType, content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s %s: %w", objType, link.sha, err)
+		}
+		queue = append(queue, links...)
+	}
+	return reachable, nil
+}
+
+func pruneObjects(repoRoot string, cutoff time.Time, dryRun bool) (int, error) {
+	reachable, err := markReachable(repoRoot)
+	if err != nil {
+		return 0, err
+	}
+	shas, err := common.ListObjects(repoRoot)
+	if err != nil {
+		return 0, err
+	}
+	objectsDir := filepath.Join(common.CommonDir(repoRoot), common.ObjectDir)
+	pruned := 0
+	for _, sha := range shas {
+		if reachable[sha] {
+			continue
+		}
+		objPath := filepath.Join(objectsDir, sha[:2], sha[2:])
+		stat, err := os.Stat(objPath)
+		if err != nil {
+			return pruned, err
+		}
+		if !stat.ModTime().Before(cutoff) { // still inside the grace period
+			continue
+		}
+		objType, _, err := common.ReadObjectWithType(repoRoot, sha)
+		if err != nil {
+			objType = "corrupt"
+		}
+		if dryRun {
+			fmt.Printf("would prune %s %s\n", objType, sha)
+			pruned++
+			continue
+		}
+		if err := os.Remove(objPath); err != nil {
+			return pruned, fmt.Errorf("failed to remove %s: %w", sha, err)
+		}
+		os.Remove(filepath.Join(objectsDir, sha[:2])) // only succeeds once the folder is empty
+		pruned++
+	}
+	return pruned, nil
+}
+
+func runPrune(expiry string, dryRun bool) {
+	repoPath, err := common.FindRepoRoot()
+	if err != nil {
+		log.Fatal(err)
+	}
+	cutoff, err := parseExpiry(expiry, time.Now())
+	if err != nil {
+		log.Fatal(err)
+	}
+	pruned, err := pruneObjects(repoPath, cutoff, dryRun)
+	if err != nil {
+		log.Fatalf("failed to prune objects: %v", err)
+	}
+	if !dryRun {
+		fmt.Printf("pruned %d unreachable objects\n", pruned)
+	}
+}
+
+func PruneCommand(cmd *cobra.Command, args []string) {
+	expiry, _ := cmd.Flags().GetString("expire")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	runPrune(expiry, dryRun)
+}
+
+func GcCommand(cmd *cobra.Command, args []string) {
+	expiry, _ := cmd.Flags().GetString("prune")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	runPrune(expiry, dryRun)
+}
@@ -0,0 +1,225 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hanzala211/mini-git/common"
+	"github.com/spf13/cobra"
+)
+
+type grepOptions struct {
+	lineNumbers bool
+	filesOnly   bool
+	countOnly   bool
+}
+
+// grepFile is one file to search, either a blob from the object store or a
+// tracked file in the working tree when sha is empty
+type grepFile struct {
+	path string
+	sha  string
+}
+
+// grepMatch is one matching line, number is 1-based
+type grepMatch struct {
+	number int
+	line   string
+}
+
+type grepResult struct {
+	matches []grepMatch
+	binary  bool
+	err     error
+}
+
+// matchesPathspec reports whether path is one of the paths or inside one of them
+func matchesPathspec(path string, pathspecs []string) bool {
+	if len(pathspecs) == 0 {
+		return true
+	}
+	for _, spec := range pathspecs {
+		if spec == "." || path == spec || strings.HasPrefix(path, spec+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// grepContent returns the matching lines of content, binary files only report whether they match
+func grepContent(pattern *regexp.Regexp, content []byte) grepResult {
+	if bytes.IndexByte(content, 0) != -1 {
+		if pattern.Match(content) {
+			return grepResult{binary: true, matches: []grepMatch{{}}}
+		}
+		return grepResult{binary: true}
+	}
+	var result grepResult
+	for i, line := range splitLines(content) {
+		line = strings.TrimSuffix(line, "\n")
+		if pattern.MatchString(line) {
+			result.matches = append(result.matches, grepMatch{number: i + 1, line: line})
+		}
+	}
+	return result
+}
+
+// searchFiles greps the files with a pool of workers, one result per file in the same order
+func searchFiles(repoRoot string, pattern *regexp.Regexp, files []grepFile) []grepResult {
+	results := make([]grepResult, len(files))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range runtime.NumCPU() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				var content []byte
+				var err error
+				if files[i].sha != "" {
+					content, err = common.ReadObject(repoRoot, files[i].sha)
+				} else {
+					content, err = os.ReadFile(filepath.Join(repoRoot, filepath.FromSlash(files[i].path)))
+				}
+				if err != nil {
+					results[i] = grepResult{err: fmt.Errorf("failed to read %s: %w", files[i].path, err)}
+					continue
+				}
+				results[i] = grepContent(pattern, content)
+			}
+		}()
+	}
+	for i := range files {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// grepSources lists the files to search: the tree of rev, the index with
+// cached, or the tracked files of the working tree
+func grepSources(repoRoot string, rev string, cached bool, pathspecs []string) ([]grepFile, error) {
+	var entries common.Index
+	var err error
+	if rev != "" {
+		treeSha, err := common.ResolveTree(repoRoot, rev)
+		if err != nil {
+			return nil, err
+		}
+		if entries, err = treeIndex(repoRoot, treeSha); err != nil {
+			return nil, err
+		}
+	} else if entries, err = common.ReadIndex(repoRoot); err != nil {
+		return nil, err
+	}
+	var files []grepFile
+	for path, sha := range entries {
+		if !matchesPathspec(path, pathspecs) {
+			continue
+		}
+		if rev == "" && !cached {
+			if _, err := os.Stat(filepath.Join(repoRoot, filepath.FromSlash(path))); os.IsNotExist(err) {
+				continue // deleted in the working tree
+			}
+			sha = ""
+		}
+		files = append(files, grepFile{path: path, sha: sha})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].path < files[j].path })
+	return files, nil
+}
+
+// printGrep prints the results and reports whether anything matched
+func printGrep(prefix string, files []grepFile, results []grepResult, options grepOptions) bool {
+	found := false
+	for i, result := range results {
+		if result.err != nil {
+			log.Fatal(result.err)
+		}
+		if len(result.matches) == 0 {
+			continue
+		}
+		found = true
+		name := prefix + files[i].path
+		switch {
+		case options.filesOnly:
+			fmt.Println(name)
+		case options.countOnly:
+			fmt.Printf("%s:%d\n", name, len(result.matches))
+		case result.binary:
+			fmt.Printf("Binary file %s matches\n", name)
+		default:
+			for _, match := range result.matches {
+				if options.lineNumbers {
+					fmt.Printf("%s:%d:%s\n", name, match.number, match.line)
+				} else {
+					fmt.Printf("%s:%s\n", name, match.line)
+				}
+			}
+		}
+	}
+	return found
+}
+
+func GrepCommand(cmd *cobra.Command, args []string) {
+	repoPath, err := common.FindRepoRoot()
+	if err != nil {
+		log.Fatal(err)
+	}
+	var pathspecs []string
+	if dash := cmd.ArgsLenAtDash(); dash != -1 {
+		for _, arg := range args[dash:] {
+			path, err := repoRelativePath(repoPath, arg)
+			if err != nil {
+				log.Fatal(err)
+			}
+			pathspecs = append(pathspecs, path)
+		}
+		args = args[:dash]
+	}
+	if len(args) == 0 || len(args) > 2 {
+		log.Fatal("usage: mini-git grep [-i] [-n] [-l] [-c] [--cached] <pattern> [<rev>] [-- <path>...]")
+	}
+	rev := ""
+	if len(args) == 2 {
+		rev = args[1]
+	}
+	cached, _ := cmd.Flags().GetBool("cached")
+	if cached && rev != "" {
+		log.Fatal("--cached cannot be used with a revision")
+	}
+
+	expr := args[0]
+	if ignoreCase, _ := cmd.Flags().GetBool("ignore-case"); ignoreCase {
+		expr = "(?i)" + expr
+	}
+	pattern, err := regexp.Compile(expr)
+	if err != nil {
+		log.Fatalf("invalid pattern: %v", err)
+	}
+	var options grepOptions
+	options.lineNumbers, _ = cmd.Flags().GetBool("line-number")
+	options.filesOnly, _ = cmd.Flags().GetBool("files-with-matches")
+	options.countOnly, _ = cmd.Flags().GetBool("count")
+
+	files, err := grepSources(repoPath, rev, cached, pathspecs)
+	if err != nil {
+		log.Fatal(err)
+	}
+	prefix := ""
+	if rev != "" {
+		prefix = rev + ":"
+	}
+	if !printGrep(prefix, files, searchFiles(repoPath, pattern, files), options) {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/hanzala211/mini-git/common"
+	"github.com/spf13/cobra"
+)
+
+func hashContent(content []byte, objType string, write bool) string {
+	if !write {
+		return common.HashObject(content, objType)
+	}
+	repoPath, err := common.FindRepoRoot()
+	if err != nil {
+		log.Fatal(err)
+	}
+	sha, err := common.WriteObject(repoPath, content, objType, "")
+	if err != nil {
+		log.Fatalf("failed to write object: %v", err)
+	}
+	return sha
+}
+
+func HashObjectCommand(cmd *cobra.Command, args []string) {
+	write, _ := cmd.Flags().GetBool("write")
+	fromStdin, _ := cmd.Flags().GetBool("stdin")
+	objType, _ := cmd.Flags().GetString("type")
+	if objType != common.BlobFile && objType != common.TreeFile && objType != common.CommitFile && objType != common.TagFile {
+		log.Fatalf("invalid object type %q", objType)
+	}
+	if !fromStdin && len(args) == 0 {
+		log.Fatal("nothing to hash, pass a file or --stdin")
+	}
+
+	if fromStdin {
+		content, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatalf("failed to read stdin: %v", err)
+		}
+		fmt.Println(hashContent(content, objType, write))
+	}
+	for _, filePath := range args {
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			log.Fatalf("Failed to read file: %v", err)
+		}
+		fmt.Println(hashContent(content, objType, write))
+	}
+}
@@ -0,0 +1,67 @@
+package commands
+
+import (
+	"github.com/hanzala211/mini-git/common"
+)
+
+// ancestorSet returns every commit reachable from sha, including sha itself
+func ancestorSet(repoRoot string, sha string) (map[string]bool, error) {
+	seen := make(map[string]bool)
+	queue := []string{sha}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current == "" || seen[current] {
+			continue
+		}
+		seen[current] = true
+		commit, err := common.ReadCommit(repoRoot, current)
+		if err != nil {
+			return nil, err
+		}
+		queue = append(queue, commit.Parents...)
+	}
+	return seen, nil
+}
+
+// commitsBetween returns the commits reachable from include but not from
+// exclude, parents always come before their children
+func commitsBetween(repoRoot string, exclude string, include string) ([]string, error) {
+	excluded := make(map[string]bool)
+	if exclude != "" {
+		var err error
+		if excluded, err = ancestorSet(repoRoot, exclude); err != nil {
+			return nil, err
+		}
+	}
+	return commitsExcluding(repoRoot, excluded, include)
+}
+
+// commitsExcluding returns the commits reachable from include without going
+// through an excluded commit, parents always come before their children
+func commitsExcluding(repoRoot string, excluded map[string]bool, include string) ([]string, error) {
+	var ordered []string
+	visited := make(map[string]bool)
+	var visit func(sha string) error
+	visit = func(sha string) error {
+		if sha == "" || visited[sha] || excluded[sha] {
+			return nil
+		}
+		visited[sha] = true
+		commit, err := common.ReadCommit(repoRoot, sha)
+		if err != nil {
+			return err
+		}
+		for _, parent := range commit.Parents {
+			if err := visit(parent); err != nil {
+				return err
+			}
+		}
+		ordered = append(ordered, sha)
+		return nil
+	}
+	if err := visit(include); err != nil {
+		return nil, err
+	}
+	return ordered, nil
+}
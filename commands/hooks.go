@@ -0,0 +1,67 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/hanzala211/mini-git/common"
+)
+
+// runHook runs .minigit/hooks/<name> from the top of the working tree if it
+// exists and is executable. Its output goes to stderr so it never mixes with
+// what the command prints, and a non-zero exit is returned as an error for
+// the caller to decide whether it aborts.
+func runHook(repoRoot string, name string, args ...string) error {
+	path := filepath.Join(common.CommonDir(repoRoot), common.HooksDir, name)
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() || info.Mode()&0111 == 0 {
+		return nil // no hook, or one that was not made executable, like git
+	}
+	cmd := exec.Command(path, args...)
+	cmd.Dir = repoRoot
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	cmd.Env = append(os.Environ(),
+		"MINIGIT_DIR="+common.MinigitDir(repoRoot),
+		"MINIGIT_INDEX_FILE="+filepath.Join(common.MinigitDir(repoRoot), common.IndexFile),
+	)
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return fmt.Errorf("%s hook exited with status %d", name, exitErr.ExitCode())
+		}
+		return fmt.Errorf("failed to run %s hook: %w", name, err)
+	}
+	return nil
+}
+
+// runCommitMsgHook hands the message to the commit-msg hook in COMMIT_EDITMSG
+// and returns it as the hook left it, hooks may rewrite the message
+func runCommitMsgHook(repoRoot string, message string) (string, error) {
+	path := filepath.Join(common.MinigitDir(repoRoot), commitEditMsgFile)
+	if err := os.WriteFile(path, []byte(message+"\n"), 0644); err != nil {
+		return "", err
+	}
+	if err := runHook(repoRoot, "commit-msg", path); err != nil {
+		return "", err
+	}
+	edited, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	message = stripComments(string(edited))
+	if message == "" {
+		return "", fmt.Errorf("aborting commit due to empty commit message")
+	}
+	return message, nil
+}
+
+// hookSha passes "no commit" to hooks as the zero sha like git does
+func hookSha(sha string) string {
+	if sha == "" {
+		return common.ZeroSha
+	}
+	return sha
+}
@@ -0,0 +1,564 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/hanzala211/mini-git/common"
+	"github.com/spf13/cobra"
+)
+
+// attributesFile lists the large file patterns as "<pattern> filter=lfs"
+// lines, it is committed like any file so every clone tracks the same paths
+const attributesFile = ".minigitattributes"
+
+const lfsFilter = "filter=lfs"
+
+// lfsTracker says which paths are stored as large files
+type lfsTracker struct {
+	patterns []string
+}
+
+func readAttributeLines(repoRoot string) ([]string, error) {
+	content, err := os.ReadFile(filepath.Join(repoRoot, attributesFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimRight(string(content), "\n"), "\n"), nil
+}
+
+func loadLFSTracker(repoRoot string) (*lfsTracker, error) {
+	lines, err := readAttributeLines(repoRoot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", attributesFile, err)
+	}
+	return parseLFSTracker(lines), nil
+}
+
+// indexLFSTracker reads the patterns from the attributes file of an index or
+// flattened tree, which is what decides how the files of that tree check out
+func indexLFSTracker(repoRoot string, index common.Index) (*lfsTracker, error) {
+	blobSha, ok := index[attributesFile]
+	if !ok {
+		return &lfsTracker{}, nil
+	}
+	content, err := common.ReadObject(repoRoot, blobSha)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", attributesFile, err)
+	}
+	return parseLFSTracker(strings.Split(strings.TrimRight(string(content), "\n"), "\n")), nil
+}
+
+// treeLFSTracker reads the patterns from the attributes file of a tree
+func treeLFSTracker(repoRoot string, treeSha string) (*lfsTracker, error) {
+	blobSha, err := blobAtPath(repoRoot, treeSha, attributesFile)
+	if err != nil {
+		return nil, err
+	}
+	if blobSha == "" {
+		return &lfsTracker{}, nil
+	}
+	return indexLFSTracker(repoRoot, common.Index{attributesFile: blobSha})
+}
+
+func parseLFSTracker(lines []string) *lfsTracker {
+	tracker := &lfsTracker{}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) > 1 && !strings.HasPrefix(fields[0], "#") && fields[1] == lfsFilter {
+			tracker.patterns = append(tracker.patterns, fields[0])
+		}
+	}
+	return tracker
+}
+
+// tracks matches like gitattributes: a pattern without a slash matches the
+// file name in any directory, one with a slash matches the whole path
+func (t *lfsTracker) tracks(relPath string) bool {
+	for _, pattern := range t.patterns {
+		target := path.Base(relPath)
+		if strings.Contains(pattern, "/") {
+			pattern, target = strings.TrimPrefix(pattern, "/"), relPath
+		}
+		if matched, _ := path.Match(pattern, target); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanFile returns the pointer blob a tracked file is committed as, storing
+// its content in the large file store when store is set. A file that is
+// already a pointer, because its content was never fetched, stays one.
+func cleanFile(repoRoot string, file *os.File, size int64, store bool) ([]byte, error) {
+	if size <= common.LFSPointerMaxSize {
+		if pointer, ok := common.ReadLFSPointer(file); ok {
+			return pointer.Bytes(), nil
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+	var pointer common.LFSPointer
+	var err error
+	if store {
+		pointer, err = common.WriteLFSObject(repoRoot, file)
+	} else {
+		pointer, err = common.HashLFSContent(file)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pointer.Bytes(), nil
+}
+
+// smudgeBlob returns what a blob is checked out as and its size: for a path
+// lfs tracks, the content of a pointer when the large file store has it,
+// otherwise the blob itself. The returned closer is nil when there is nothing
+// to close.
+func smudgeBlob(repoRoot string, lfs *lfsTracker, blob *common.ObjectReader, fullPath string) (io.Reader, int64, io.Closer, error) {
+	relPath, err := filepath.Rel(repoRoot, fullPath)
+	if err != nil || blob.Size > common.LFSPointerMaxSize || !lfs.tracks(filepath.ToSlash(relPath)) {
+		return blob, blob.Size, nil, nil
+	}
+	data, err := io.ReadAll(blob)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	pointer, ok := common.ParseLFSPointer(data)
+	if !ok {
+		return bytes.NewReader(data), int64(len(data)), nil, nil
+	}
+	content, err := common.OpenLFSContent(repoRoot, pointer)
+	if os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "warning: the content of %s is not in the large file store, run \"mini-git lfs fetch\"\n", filepath.ToSlash(relPath))
+		return bytes.NewReader(data), int64(len(data)), nil, nil
+	}
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	return content, pointer.Size, content, nil // OpenLFSContent checked the size
+}
+
+// blobPointers returns the pointers among blobs, keyed by oid
+func blobPointers(repoRoot string, blobs map[string]bool) (map[string]common.LFSPointer, error) {
+	pointers := make(map[string]common.LFSPointer)
+	for sha := range blobs {
+		pointer, ok, err := common.ReadBlobLFSPointer(repoRoot, sha)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			pointers[pointer.Oid] = pointer
+		}
+	}
+	return pointers, nil
+}
+
+// checkoutPointers returns the pointers of HEAD and the index, which is what
+// the working tree needs, and with all those of every commit reachable from a ref
+func checkoutPointers(repoRoot string, all bool) (map[string]common.LFSPointer, error) {
+	blobs := make(map[string]bool)
+	index, err := common.ReadIndex(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	headSha, err := common.GetParentSha(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	head, err := commitIndex(repoRoot, headSha)
+	if err != nil {
+		return nil, err
+	}
+	for _, entries := range []common.Index{index, head} {
+		for _, sha := range entries {
+			blobs[sha] = true
+		}
+	}
+	if all {
+		refs, err := common.ListRefs(repoRoot)
+		if err != nil {
+			return nil, err
+		}
+		var tips []string
+		for _, sha := range refs {
+			tips = append(tips, sha)
+		}
+		reachable, err := reachableObjects(repoRoot, tips)
+		if err != nil {
+			return nil, err
+		}
+		for sha := range reachable {
+			blobs[sha] = true
+		}
+	}
+	return blobPointers(repoRoot, blobs)
+}
+
+// commitPointers returns the pointers in the tree of a commit, or of the commit a tag points to
+func commitPointers(repoRoot string, sha string) (map[string]common.LFSPointer, error) {
+	commitSha, err := common.PeelTag(repoRoot, sha)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := commitIndex(repoRoot, commitSha)
+	if err != nil {
+		return nil, err
+	}
+	blobs := make(map[string]bool)
+	for _, blobSha := range entries {
+		blobs[blobSha] = true
+	}
+	return blobPointers(repoRoot, blobs)
+}
+
+// refPointers returns the pointers in the trees of every ref tip, and the
+// HEAD and index of every worktree
+func refPointers(repoRoot string) (map[string]common.LFSPointer, error) {
+	worktrees, err := common.ListWorktrees(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	pointers := make(map[string]common.LFSPointer)
+	for _, worktree := range worktrees {
+		if worktree.Prunable() != "" {
+			continue
+		}
+		checkedOut, err := checkoutPointers(worktree.Path, false)
+		if err != nil {
+			return nil, err
+		}
+		maps.Copy(pointers, checkedOut)
+	}
+	refs, err := common.ListRefs(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	for _, sha := range refs {
+		tipPointers, err := commitPointers(repoRoot, sha)
+		if err != nil {
+			return nil, err
+		}
+		maps.Copy(pointers, tipPointers)
+	}
+	return pointers, nil
+}
+
+// localRemoteRoot returns the repository a remote points to, large files
+// are only exchanged with repositories on the same filesystem
+func localRemoteRoot(repoRoot string, name string) (string, error) {
+	config, err := common.ReadConfig(repoRoot)
+	if err != nil {
+		return "", err
+	}
+	remoteConfig, ok := config.Remotes[name]
+	if !ok {
+		return "", fmt.Errorf("'%s' does not appear to be a mini-git repository", name)
+	}
+	transport, err := openTransport(remoteConfig.URL)
+	if err != nil {
+		return "", err
+	}
+	defer transport.Close()
+	local, ok := transport.(*localTransport)
+	if !ok {
+		return "", fmt.Errorf("large files can only be fetched from a local repository, %s is %s", name, remoteConfig.URL)
+	}
+	return local.root, nil
+}
+
+// fetchLFSObjects copies the large files in pointers that are missing here
+// from remoteRoot, checking each against its oid
+func fetchLFSObjects(repoRoot string, remoteRoot string, pointers map[string]common.LFSPointer) (int, error) {
+	oids := make([]string, 0, len(pointers))
+	for oid := range pointers {
+		oids = append(oids, oid)
+	}
+	sort.Strings(oids)
+	fetched := 0
+	for _, oid := range oids {
+		if common.LFSObjectExists(repoRoot, oid) {
+			continue
+		}
+		content, err := common.OpenLFSObject(remoteRoot, oid)
+		if os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "warning: large file %s is missing on the remote too\n", oid[:10])
+			continue
+		}
+		if err != nil {
+			return fetched, err
+		}
+		pointer, err := common.WriteLFSObject(repoRoot, content)
+		content.Close()
+		if err != nil {
+			return fetched, err
+		}
+		if pointer != pointers[oid] {
+			common.RemoveLFSObject(repoRoot, pointer.Oid)
+			return fetched, fmt.Errorf("large file %s on the remote is corrupt", oid)
+		}
+		fetched++
+	}
+	return fetched, nil
+}
+
+// smudgeWorktree replaces the tracked files that are still pointers with
+// their content, once it is in the store
+func smudgeWorktree(repoRoot string) (int, error) {
+	index, err := common.ReadIndex(repoRoot)
+	if err != nil {
+		return 0, err
+	}
+	lfs, err := loadLFSTracker(repoRoot)
+	if err != nil {
+		return 0, err
+	}
+	updated := 0
+	for relPath, sha := range index {
+		if !lfs.tracks(relPath) {
+			continue
+		}
+		pointer, ok, err := common.ReadBlobLFSPointer(repoRoot, sha)
+		if err != nil {
+			return updated, err
+		}
+		if !ok || !common.LFSObjectExists(repoRoot, pointer.Oid) {
+			continue
+		}
+		fullPath := filepath.Join(repoRoot, filepath.FromSlash(relPath))
+		file, err := os.Open(fullPath)
+		if err != nil {
+			continue // deleted in the working tree
+		}
+		onDisk, isPointer := common.ReadLFSPointer(file)
+		file.Close()
+		if !isPointer || onDisk != pointer {
+			continue
+		}
+		if err := writeBlobToWorktree(repoRoot, lfs, sha, fullPath); err != nil {
+			return updated, err
+		}
+		updated++
+	}
+	return updated, nil
+}
+
+func lfsTrack(repoRoot string, patterns []string) {
+	lines, err := readAttributeLines(repoRoot)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(patterns) == 0 {
+		tracker, err := loadLFSTracker(repoRoot)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("Listing tracked patterns")
+		for _, pattern := range tracker.patterns {
+			fmt.Printf("    %s (%s)\n", pattern, attributesFile)
+		}
+		return
+	}
+	tracker, err := loadLFSTracker(repoRoot)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, pattern := range patterns {
+		if strings.ContainsAny(pattern, " \t") {
+			log.Fatalf("pattern %q cannot contain whitespace", pattern)
+		}
+		if slices.Contains(tracker.patterns, pattern) {
+			fmt.Printf("\"%s\" already supported\n", pattern)
+			continue
+		}
+		lines = append(lines, pattern+" "+lfsFilter)
+		tracker.patterns = append(tracker.patterns, pattern)
+		fmt.Printf("Tracking \"%s\"\n", pattern)
+	}
+	writeAttributeLines(repoRoot, lines)
+}
+
+func lfsUntrack(repoRoot string, patterns []string) {
+	lines, err := readAttributeLines(repoRoot)
+	if err != nil {
+		log.Fatal(err)
+	}
+	remove := make(map[string]bool)
+	for _, pattern := range patterns {
+		remove[pattern] = true
+	}
+	var kept []string
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) > 1 && fields[1] == lfsFilter && remove[fields[0]] {
+			fmt.Printf("Untracking \"%s\"\n", fields[0])
+			continue
+		}
+		kept = append(kept, line)
+	}
+	writeAttributeLines(repoRoot, kept)
+}
+
+func writeAttributeLines(repoRoot string, lines []string) {
+	content := strings.Join(lines, "\n")
+	if content != "" {
+		content += "\n"
+	}
+	if err := os.WriteFile(filepath.Join(repoRoot, attributesFile), []byte(content), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// lfsLsFiles lists the large files in the index, "*" marks those whose
+// content is in the store and "-" those that are only a pointer here
+func lfsLsFiles(repoRoot string) {
+	index, err := common.ReadIndex(repoRoot)
+	if err != nil {
+		log.Fatal(err)
+	}
+	paths := make([]string, 0, len(index))
+	for relPath := range index {
+		paths = append(paths, relPath)
+	}
+	sort.Strings(paths)
+	for _, relPath := range paths {
+		pointer, ok, err := common.ReadBlobLFSPointer(repoRoot, index[relPath])
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !ok {
+			continue
+		}
+		marker := "-"
+		if common.LFSObjectExists(repoRoot, pointer.Oid) {
+			marker = "*"
+		}
+		fmt.Printf("%s %s %s (%d bytes)\n", pointer.Oid[:10], marker, relPath, pointer.Size)
+	}
+}
+
+func lfsFetch(repoRoot string, args []string, all bool) {
+	remote := defaultRemote
+	if len(args) > 0 {
+		remote = args[0]
+	}
+	remoteRoot, err := localRemoteRoot(repoRoot, remote)
+	if err != nil {
+		log.Fatal(err)
+	}
+	pointers, err := checkoutPointers(repoRoot, all)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fetched, err := fetchLFSObjects(repoRoot, remoteRoot, pointers)
+	if err != nil {
+		log.Fatal(err)
+	}
+	updated, err := smudgeWorktree(repoRoot)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Fetched %d large files, updated %d files in the working tree\n", fetched, updated)
+}
+
+// lfsPrune deletes the large files no ref tip, HEAD or index points to.
+// Unless forced, a file is only deleted when a local remote still has it, so
+// old versions can always be fetched again.
+func lfsPrune(repoRoot string, dryRun bool, force bool) {
+	keep, err := refPointers(repoRoot)
+	if err != nil {
+		log.Fatal(err)
+	}
+	oids, err := common.ListLFSObjects(repoRoot)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var remoteRoots []string
+	if !force {
+		config, err := common.ReadConfig(repoRoot)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for name := range config.Remotes {
+			if root, err := localRemoteRoot(repoRoot, name); err == nil {
+				remoteRoots = append(remoteRoots, root)
+			}
+		}
+	}
+	sort.Strings(oids)
+	pruned, kept := 0, 0
+	var freed int64
+	for _, oid := range oids {
+		if _, referenced := keep[oid]; referenced {
+			continue
+		}
+		onRemote := force
+		for _, root := range remoteRoots {
+			onRemote = onRemote || common.LFSObjectExists(root, oid)
+		}
+		if !onRemote {
+			kept++
+			continue
+		}
+		if content, err := common.OpenLFSObject(repoRoot, oid); err == nil {
+			if info, err := content.Stat(); err == nil {
+				freed += info.Size()
+			}
+			content.Close()
+		}
+		if dryRun {
+			fmt.Printf("Would prune %s\n", oid[:10])
+		} else if err := common.RemoveLFSObject(repoRoot, oid); err != nil {
+			log.Fatal(err)
+		}
+		pruned++
+	}
+	if dryRun {
+		fmt.Printf("Would prune %d large files (%d bytes)\n", pruned, freed)
+	} else {
+		fmt.Printf("Pruned %d large files (%d bytes)\n", pruned, freed)
+	}
+	if kept > 0 {
+		fmt.Printf("Kept %d unreferenced large files that no local remote has, use --force to delete them\n", kept)
+	}
+}
+
+func LFSCommand(cmd *cobra.Command, args []string) {
+	repoPath, err := common.FindRepoRoot()
+	if err != nil {
+		log.Fatal(err)
+	}
+	switch args[0] {
+	case "track":
+		lfsTrack(repoPath, args[1:])
+	case "untrack":
+		if len(args) < 2 {
+			log.Fatal("usage: mini-git lfs untrack <pattern>...")
+		}
+		lfsUntrack(repoPath, args[1:])
+	case "ls-files":
+		lfsLsFiles(repoPath)
+	case "fetch":
+		all, _ := cmd.Flags().GetBool("all")
+		lfsFetch(repoPath, args[1:], all)
+	case "prune":
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		force, _ := cmd.Flags().GetBool("force")
+		lfsPrune(repoPath, dryRun, force)
+	default:
+		log.Fatalf("unknown lfs command %q, expected track, untrack, ls-files, fetch or prune", args[0])
+	}
+}
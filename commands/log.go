@@ -0,0 +1,87 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/hanzala211/mini-git/common"
+	"github.com/spf13/cobra"
+)
+
+const logDateFormat = "Mon Jan 2 15:04:05 2006 -0700"
+
+// printSignatureCheck is the --show-signature line of a log entry
+func printSignatureCheck(repoRoot string, sha string) {
+	check, err := checkCommitSignature(repoRoot, sha)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(check)
+}
+
+func printLogEntry(repoRoot string, sha string, commit *common.Commit, oneline bool, showSignature bool) {
+	if oneline {
+		fmt.Printf("%s %s\n", sha[:7], firstLine(commit.Message))
+		if showSignature {
+			printSignatureCheck(repoRoot, sha)
+		}
+		return
+	}
+	fmt.Printf("commit %s\n", sha)
+	if showSignature {
+		printSignatureCheck(repoRoot, sha)
+	}
+	if len(commit.Parents) > 1 {
+		short := make([]string, len(commit.Parents))
+		for i, parent := range commit.Parents {
+			short[i] = parent[:7]
+		}
+		fmt.Printf("Merge: %s\n", strings.Join(short, " "))
+	}
+	if author := commit.AuthorName(); author != "" {
+		fmt.Printf("Author: %s\n", author)
+	}
+	if date, err := commit.AuthorTime(); err == nil {
+		fmt.Printf("Date:   %s\n", date.Format(logDateFormat))
+	}
+	fmt.Println()
+	for _, line := range strings.Split(commit.Message, "\n") {
+		fmt.Printf("    %s\n", line)
+	}
+	fmt.Println()
+}
+
+func LogCommand(cmd *cobra.Command, args []string) {
+	repoPath, err := common.FindRepoRoot()
+	if err != nil {
+		log.Fatal(err)
+	}
+	rev := common.HEAD
+	if len(args) > 0 {
+		rev = args[0]
+	}
+	sha, err := common.ResolveCommit(repoPath, rev)
+	if err != nil {
+		log.Fatal(err)
+	}
+	maxCount, _ := cmd.Flags().GetInt("max-count")
+	oneline, _ := cmd.Flags().GetBool("oneline")
+	showSignature, _ := cmd.Flags().GetBool("show-signature")
+
+	commits, err := commitsBetween(repoPath, "", sha)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// parents come before children, the log shows the newest first
+	for i := len(commits) - 1; i >= 0; i-- {
+		if maxCount >= 0 && len(commits)-1-i >= maxCount {
+			break
+		}
+		commit, err := common.ReadCommit(repoPath, commits[i])
+		if err != nil {
+			log.Fatal(err)
+		}
+		printLogEntry(repoPath, commits[i], commit, oneline, showSignature)
+	}
+}
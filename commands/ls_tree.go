@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"sort"
+
+	"github.com/hanzala211/mini-git/common"
+	"github.com/spf13/cobra"
+)
+
+func modeToType(mode string) string {
+	if mode == "040000" {
+		return common.TreeFile
+	}
+	return common.BlobFile
+}
+
+func listTree(repoRoot string, treeSha string, prefix string, recursive bool) {
+	treeData, err := common.ReadObject(repoRoot, treeSha)
+	if err != nil {
+		log.Fatalf("failed to read tree %s: %v", treeSha, err)
+	}
+	entries, err := parseTreeToMap(treeData)
+	if err != nil {
+		log.Fatalf("failed to parse tree: %v", err)
+	}
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		entry := entries[name]
+		fullName := path.Join(prefix, name)
+		if recursive && entry.Mode == "040000" {
+			listTree(repoRoot, entry.SHA, fullName, recursive)
+			continue
+		}
+		fmt.Printf("%s %s %s\t%s\n", entry.Mode, modeToType(entry.Mode), entry.SHA, fullName)
+	}
+}
+
+func LsTreeCommand(cmd *cobra.Command, args []string) {
+	repoPath, err := common.FindRepoRoot()
+	if err != nil {
+		log.Fatal(err)
+	}
+	recursive, _ := cmd.Flags().GetBool("recursive")
+	treeSha, err := common.ResolveTree(repoPath, args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	listTree(repoPath, treeSha, "", recursive)
+}
@@ -1,11 +1,8 @@
 package commands
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
-	"os"
-	"path/filepath"
 	"strings"
 
 	"github.com/hanzala211/mini-git/common"
@@ -17,27 +14,28 @@ func MergeCommand(cmd *cobra.Command, args []string) {
 	if err != nil {
 		log.Fatal(err)
 	}
-	currentBranch, err := common.GetHeadRef(repoPath)
-	if err != nil {
-		log.Fatal(err)
+	currentBranch := currentBranchName(repoPath)
+	if currentBranch == common.HEAD {
+		log.Fatal("cannot merge into a detached HEAD")
 	}
-	currentBranch = strings.Split(currentBranch, "/")[2]
 	newBranch := args[0]
 	if currentBranch == newBranch {
 		fmt.Println("Already on the branch you are trying to merge")
 		return
 	}
-	newBranchCommit, err := os.ReadFile(filepath.Join(repoPath, common.RootDir, common.RefsDir, common.HeadDir, newBranch))
+	newBranchCommitSHA, err := common.ReadRef(repoPath, "refs/heads/"+newBranch)
 	if err != nil {
 		log.Fatal(err)
 	}
-	newBranchCommitSHA := strings.TrimSpace(string(newBranchCommit))
 	oldBranchCommit, err := common.GetParentSha(repoPath)
 	if err != nil {
 		log.Fatal(err)
 	}
 	if isAncestor(repoPath, oldBranchCommit, newBranchCommitSHA) {
-		if err := os.WriteFile(filepath.Join(repoPath, common.RootDir, common.RefsDir, common.HeadDir, currentBranch), newBranchCommit, 0644); err != nil {
+		// fails instead of dropping commits if the branch moved since it was read
+		transaction := common.NewRefTransaction(repoPath)
+		transaction.Update("refs/heads/"+currentBranch, newBranchCommitSHA, oldBranchCommit, "merge "+newBranch+": Fast-forward")
+		if err := transaction.Commit(); err != nil {
 			log.Fatal(err)
 		}
 		newTreeShaByt, _ := common.ReadObject(repoPath, newBranchCommitSHA)
@@ -64,53 +62,41 @@ func MergeCommand(cmd *cobra.Command, args []string) {
 				log.Fatalf("failed to build index from tree: %v", err)
 			}
 
-			indexBytes, err := json.MarshalIndent(newIndex, "", "  ")
-			if err != nil {
-				log.Fatalf("failed to marshal index: %v", err)
-			}
-			indexPath := filepath.Join(repoPath, common.RootDir, common.IndexFile)
-			if err := os.WriteFile(indexPath, indexBytes, 0644); err != nil {
+			if err := common.WriteIndex(repoPath, newIndex); err != nil {
 				log.Fatalf("failed to write index: %v", err)
 			}
 		} else {
 			// Empty branch - clear the index
 			emptyIndex := make(common.Index)
-			indexBytes, err := json.MarshalIndent(emptyIndex, "", "  ")
-			if err != nil {
-				log.Fatalf("failed to marshal index: %v", err)
-			}
-			indexPath := filepath.Join(repoPath, common.RootDir, common.IndexFile)
-			if err := os.WriteFile(indexPath, indexBytes, 0644); err != nil {
+			if err := common.WriteIndex(repoPath, emptyIndex); err != nil {
 				log.Fatalf("failed to write index: %v", err)
 			}
 		}
 		return
 	}
+	// pre-merge-commit belongs here, right before the merge commit is written,
+	// once 3-way merges exist
 	log.Fatal("3-way merges are not yet implemented")
 }
 
 func isAncestor(repoRoot string, possibleAncestorCommit string, commit string) bool {
-	for commit != "" {
+	queue := []string{commit}
+	seen := make(map[string]bool)
+	for len(queue) > 0 {
+		commit = queue[0]
+		queue = queue[1:]
+		if commit == "" || seen[commit] {
+			continue
+		}
 		if possibleAncestorCommit == commit {
 			return true
 		}
-		commitObj, err := common.ReadObject(repoRoot, commit)
+		seen[commit] = true
+		commitObj, err := common.ReadCommit(repoRoot, commit)
 		if err != nil {
 			log.Fatal(err)
 		}
-		commitObjStr := strings.TrimSpace(string(commitObj))
-		lines := strings.Split(commitObjStr, "\n")
-		if len(lines) < 2 {
-			// No parent commit (initial commit or orphan commit)
-			return false
-		}
-		parentLine := strings.Split(lines[1], " ")
-		if len(parentLine) < 2 {
-			// Invalid parent line format
-			return false
-		}
-		commit = parentLine[1]
-		commit = strings.TrimSpace(commit)
+		queue = append(queue, commitObj.Parents...) // an initial commit has no parents
 	}
 	return false
 }
@@ -0,0 +1,153 @@
+package commands
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/hanzala211/mini-git/common"
+)
+
+// mergeResult is the outcome of a three-way merge of two flattened trees
+type mergeResult struct {
+	Index     common.Index      // merged entries, conflicted paths keep the "ours" version
+	Conflicts []string          // paths that could not be merged cleanly
+	Worktree  map[string][]byte // content to write for conflicted paths (with conflict markers)
+}
+
+// mergeLines does a diff3 style merge of ours and theirs against base, returning
+// the merged content and whether any conflict markers were written
+func mergeLines(base []byte, ours []byte, theirs []byte, oursLabel string, theirsLabel string) ([]byte, bool) {
+	o, a, b := splitLines(base), splitLines(ours), splitLines(theirs)
+	matchA, matchB := matchLines(o, a), matchLines(o, b)
+	var out strings.Builder
+	conflict := false
+
+	resolve := func(oChunk, aChunk, bChunk []string) {
+		switch {
+		case slices.Equal(aChunk, oChunk):
+			writeLines(&out, bChunk)
+		case slices.Equal(bChunk, oChunk), slices.Equal(aChunk, bChunk):
+			writeLines(&out, aChunk)
+		default:
+			conflict = true
+			out.WriteString("<<<<<<< " + oursLabel + "\n")
+			writeLines(&out, aChunk)
+			out.WriteString("=======\n")
+			writeLines(&out, bChunk)
+			out.WriteString(">>>>>>> " + theirsLabel + "\n")
+		}
+	}
+
+	i, x, y := 0, 0, 0 // positions in base, ours and theirs
+	for {
+		// copy the lines that are unchanged on both sides
+		k := 0
+		for i+k < len(o) && matchA[i+k] == x+k && matchB[i+k] == y+k {
+			k++
+		}
+		if k > 0 {
+			writeLines(&out, o[i:i+k])
+			i, x, y = i+k, x+k, y+k
+			continue
+		}
+		// find the next base line both sides still have
+		j := i
+		for j < len(o) && (matchA[j] == -1 || matchB[j] == -1) {
+			j++
+		}
+		if j == len(o) {
+			resolve(o[i:], a[x:], b[y:])
+			break
+		}
+		resolve(o[i:j], a[x:matchA[j]], b[y:matchB[j]])
+		i, x, y = j, matchA[j], matchB[j]
+	}
+	return []byte(out.String()), conflict
+}
+
+func writeLines(out *strings.Builder, lines []string) {
+	for _, line := range lines {
+		out.WriteString(line)
+	}
+	// conflict markers must start on their own line
+	if len(lines) > 0 && !strings.HasSuffix(lines[len(lines)-1], "\n") {
+		out.WriteString("\n")
+	}
+}
+
+func readBlobOrEmpty(repoRoot string, sha string) ([]byte, error) {
+	if sha == "" {
+		return nil, nil
+	}
+	return common.ReadObject(repoRoot, sha)
+}
+
+// mergeIndexes three-way merges two flattened trees against their common base,
+// clean content merges are written to the object store as blobs
+func mergeIndexes(repoRoot string, base, ours, theirs common.Index, oursLabel string, theirsLabel string) (*mergeResult, error) {
+	result := &mergeResult{Index: make(common.Index), Worktree: make(map[string][]byte)}
+	paths := make(map[string]bool)
+	for _, index := range []common.Index{base, ours, theirs} {
+		for path := range index {
+			paths[path] = true
+		}
+	}
+	sortedPaths := make([]string, 0, len(paths))
+	for path := range paths {
+		sortedPaths = append(sortedPaths, path)
+	}
+	sort.Strings(sortedPaths)
+
+	for _, path := range sortedPaths {
+		o, a, b := base[path], ours[path], theirs[path]
+		merged := ""
+		switch {
+		case a == b, b == o:
+			merged = a
+		case a == o:
+			merged = b
+		case a == "" || b == "": // modified on one side, deleted on the other
+			result.Conflicts = append(result.Conflicts, path)
+			if a != "" {
+				result.Index[path] = a
+				continue
+			}
+			content, err := readBlobOrEmpty(repoRoot, b)
+			if err != nil {
+				return nil, err
+			}
+			result.Worktree[path] = content
+			continue
+		default:
+			baseContent, err := readBlobOrEmpty(repoRoot, o)
+			if err != nil {
+				return nil, err
+			}
+			oursContent, err := readBlobOrEmpty(repoRoot, a)
+			if err != nil {
+				return nil, err
+			}
+			theirsContent, err := readBlobOrEmpty(repoRoot, b)
+			if err != nil {
+				return nil, err
+			}
+			content, conflict := mergeLines(baseContent, oursContent, theirsContent, oursLabel, theirsLabel)
+			if conflict {
+				result.Conflicts = append(result.Conflicts, path)
+				result.Index[path] = a
+				result.Worktree[path] = content
+				continue
+			}
+			merged, err = common.WriteObject(repoRoot, content, common.BlobFile, "")
+			if err != nil {
+				return nil, fmt.Errorf("failed to write merged blob for %s: %w", path, err)
+			}
+		}
+		if merged != "" {
+			result.Index[path] = merged
+		}
+	}
+	return result, nil
+}
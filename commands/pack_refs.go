@@ -0,0 +1,22 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/hanzala211/mini-git/common"
+	"github.com/spf13/cobra"
+)
+
+func PackRefsCommand(cmd *cobra.Command, args []string) {
+	repoPath, err := common.FindRepoRoot()
+	if err != nil {
+		log.Fatal(err)
+	}
+	all, _ := cmd.Flags().GetBool("all")
+	packed, err := common.PackRefs(repoPath, all)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Packed %d refs\n", packed)
+}
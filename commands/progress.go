@@ -0,0 +1,62 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// progressInterval keeps redrawing the progress line from costing more than the work
+const progressInterval = 100 * time.Millisecond
+
+// progress draws a "<title>: 42% (420/1000)" line on stderr that is
+// rewritten in place, it is safe to use from several goroutines
+type progress struct {
+	mu      sync.Mutex
+	title   string
+	total   int
+	done    int
+	enabled bool
+	drawn   time.Time
+}
+
+func newProgress(title string, total int, enabled bool) *progress {
+	return &progress{title: title, total: total, enabled: enabled && total > 0}
+}
+
+// wantProgress follows --progress when it is given, otherwise progress is
+// only shown when stderr is a terminal so scripts and logs stay clean
+func wantProgress(cmd *cobra.Command) bool {
+	if cmd.Flags().Changed("progress") {
+		show, _ := cmd.Flags().GetBool("progress")
+		return show
+	}
+	info, err := os.Stderr.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func (p *progress) draw(suffix string) {
+	fmt.Fprintf(os.Stderr, "\r%s: %3d%% (%d/%d)%s", p.title, p.done*100/p.total, p.done, p.total, suffix)
+}
+
+func (p *progress) Add(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done += n
+	if p.enabled && time.Since(p.drawn) >= progressInterval {
+		p.draw("")
+		p.drawn = time.Now()
+	}
+}
+
+// Done draws the final line and moves past it
+func (p *progress) Done() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.enabled {
+		p.draw(", done.\n")
+	}
+}
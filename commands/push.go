@@ -0,0 +1,118 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/hanzala211/mini-git/common"
+	"github.com/spf13/cobra"
+)
+
+// rejectPush reports a ref the remote will not take and exits
+func rejectPush(url string, src string, dst string, why string, hint string) {
+	fmt.Printf(" ! %-17s %s -> %s (%s)\n", "[rejected]", src, dst, why)
+	fmt.Printf("error: failed to push some refs to '%s'\n", url)
+	if hint != "" {
+		fmt.Printf("hint: %s\n", hint)
+	}
+	os.Exit(1)
+}
+
+func PushCommand(cmd *cobra.Command, args []string) {
+	repoPath, err := common.FindRepoRoot()
+	if err != nil {
+		log.Fatal(err)
+	}
+	config, err := common.ReadConfig(repoPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	force, _ := cmd.Flags().GetBool("force")
+	setUpstream, _ := cmd.Flags().GetBool("set-upstream")
+
+	remote := defaultRemoteName(repoPath, config)
+	if len(args) > 0 {
+		remote = args[0]
+	}
+	remoteConfig, ok := config.Remotes[remote]
+	if !ok {
+		log.Fatalf("no such remote '%s'", remote)
+	}
+	spec := currentBranchName(repoPath)
+	if len(args) > 1 {
+		spec = args[1]
+	} else if spec == common.HEAD {
+		log.Fatal("you are not currently on a branch, name the branch to push")
+	}
+	// <branch> pushes to the branch of the same name, <src>:<dst> to another one
+	src, dst, found := strings.Cut(spec, ":")
+	if !found {
+		dst = src
+	}
+	dst = strings.TrimPrefix(dst, "refs/heads/")
+	dstRef := "refs/heads/" + dst
+	localSha, err := common.ResolveCommit(repoPath, src)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := common.ReadCommit(repoPath, localSha); err != nil {
+		log.Fatal(err)
+	}
+
+	transport, err := openTransport(remoteConfig.URL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer transport.Close()
+	ads, err := transport.ListRefs()
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("To %s\n", remoteConfig.URL)
+	remoteOld := ads.Refs[dstRef]
+	if remoteOld == localSha {
+		fmt.Println("Everything up-to-date")
+		return
+	}
+	if remoteOld != "" && !force {
+		if !common.ObjectExists(repoPath, remoteOld) {
+			rejectPush(remoteConfig.URL, src, dst, "fetch first", "the remote has work you do not have locally, fetch and integrate it before pushing again")
+		}
+		if !isAncestor(repoPath, remoteOld, localSha) {
+			rejectPush(remoteConfig.URL, src, dst, "non-fast-forward", "your branch is behind its remote counterpart, integrate the remote changes before pushing again")
+		}
+	}
+
+	update := refUpdate{Name: dstRef, Old: remoteOld, New: localSha}
+	if _, err := transport.Push(repoPath, []refUpdate{update}); err != nil {
+		fmt.Printf(" ! %-17s %s -> %s (%v)\n", "[remote rejected]", src, dst, err)
+		fmt.Printf("error: failed to push some refs to '%s'\n", remoteConfig.URL)
+		os.Exit(1)
+	}
+	switch {
+	case remoteOld == "":
+		fmt.Printf(" * %-17s %s -> %s\n", "[new branch]", src, dst)
+	case isAncestor(repoPath, remoteOld, localSha):
+		fmt.Printf("   %-17s %s -> %s\n", remoteOld[:7]+".."+localSha[:7], src, dst)
+	default:
+		fmt.Printf(" + %-17s %s -> %s (forced update)\n", remoteOld[:7]+"..."+localSha[:7], src, dst)
+	}
+
+	trackingRef := fmt.Sprintf("%s/%s/%s/%s", common.RefsDir, common.RemotesDir, remote, dst)
+	if err := common.UpdateRef(repoPath, trackingRef, localSha, "update by push"); err != nil {
+		log.Fatal(err)
+	}
+	if setUpstream {
+		branch := strings.TrimPrefix(src, "refs/heads/")
+		if _, err := common.ReadRef(repoPath, "refs/heads/"+branch); err != nil {
+			log.Fatalf("cannot set up tracking for %s, it is not a local branch", src)
+		}
+		config.Branches[branch] = common.BranchConfig{Remote: remote, Merge: dstRef}
+		if err := common.WriteConfig(repoPath, config); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("branch '%s' set up to track '%s/%s'.\n", branch, remote, dst)
+	}
+}
@@ -0,0 +1,686 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/hanzala211/mini-git/common"
+	"github.com/spf13/cobra"
+)
+
+// a rebase in progress keeps its state in .minigit/rebase/:
+//
+//	head-name   the branch being rebased
+//	orig-head   where that branch pointed before the rebase, restored by --abort
+//	onto        the commit the branch is being replayed on
+//	todo        steps still to run, the first one is in progress when stopped
+//	done        steps already run
+//	conflicts   paths that conflicted in the current step
+//	amend       the commit an edit step stopped at, --continue amends it with the index
+//	gpg-sign    present when the new commits are signed
+const rebaseDir = "rebase"
+
+// todoActions maps every todo command and its short form to the full name
+var todoActions = map[string]string{
+	"p": "pick", "pick": "pick",
+	"r": "reword", "reword": "reword",
+	"e": "edit", "edit": "edit",
+	"s": "squash", "squash": "squash",
+	"f": "fixup", "fixup": "fixup",
+	"d": "drop", "drop": "drop",
+	"x": "exec", "exec": "exec",
+}
+
+const todoHelp = `
+# Commands:
+# p, pick <commit> = use commit
+# r, reword <commit> = use commit, but edit the commit message
+# e, edit <commit> = use commit, but stop for amending
+# s, squash <commit> = use commit, but meld into previous commit
+# f, fixup <commit> = like "squash", but discard this commit's message
+# x, exec <command> = run command (the rest of the line) using shell
+# d, drop <commit> = remove commit
+#
+# These lines can be re-ordered; they are executed from top to bottom.
+# If you remove a line here THAT COMMIT WILL BE LOST.
+# However, if you remove everything, the rebase will be aborted.
+`
+
+type rebaseStep struct {
+	Action string
+	Sha    string // empty for exec
+	Rest   string // the subject of the commit, or the command for exec
+}
+
+type rebaseState struct {
+	HeadName  string
+	OrigHead  string
+	Onto      string
+	Todo      []rebaseStep
+	Done      []rebaseStep
+	Conflicts []string
+	Amend     string
+	Sign      bool
+}
+
+func (s rebaseStep) String() string {
+	if s.Action == "exec" {
+		return s.Action + " " + s.Rest
+	}
+	return strings.TrimSpace(fmt.Sprintf("%s %s %s", s.Action, s.Sha, s.Rest))
+}
+
+func rebaseStatePath(repoRoot string, name string) string {
+	return filepath.Join(common.MinigitDir(repoRoot), rebaseDir, name)
+}
+
+func parseTodo(content string) ([]rebaseStep, error) {
+	var steps []rebaseStep
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		command, rest, _ := strings.Cut(line, " ")
+		action, ok := todoActions[command]
+		if !ok {
+			return nil, fmt.Errorf("unknown rebase command %q", command)
+		}
+		step := rebaseStep{Action: action}
+		rest = strings.TrimSpace(rest)
+		if action == "exec" {
+			if rest == "" {
+				return nil, fmt.Errorf("missing command in todo line %q", line)
+			}
+			step.Rest = rest
+		} else {
+			step.Sha, step.Rest, _ = strings.Cut(rest, " ")
+			if step.Sha == "" {
+				return nil, fmt.Errorf("missing commit in todo line %q", line)
+			}
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+func formatTodo(steps []rebaseStep) string {
+	var content strings.Builder
+	for _, step := range steps {
+		content.WriteString(step.String() + "\n")
+	}
+	return content.String()
+}
+
+// loadRebase returns nil when no rebase is in progress
+func loadRebase(repoRoot string) (*rebaseState, error) {
+	if _, err := os.Stat(filepath.Join(common.MinigitDir(repoRoot), rebaseDir)); os.IsNotExist(err) {
+		return nil, nil
+	}
+	files := make(map[string]string)
+	for _, name := range []string{"head-name", "orig-head", "onto", "todo", "done", "conflicts", "amend", "gpg-sign"} {
+		content, err := os.ReadFile(rebaseStatePath(repoRoot, name))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		files[name] = string(content)
+	}
+	todo, err := parseTodo(files["todo"])
+	if err != nil {
+		return nil, err
+	}
+	done, err := parseTodo(files["done"])
+	if err != nil {
+		return nil, err
+	}
+	return &rebaseState{
+		HeadName:  strings.TrimSpace(files["head-name"]),
+		OrigHead:  strings.TrimSpace(files["orig-head"]),
+		Onto:      strings.TrimSpace(files["onto"]),
+		Todo:      todo,
+		Done:      done,
+		Conflicts: strings.Fields(files["conflicts"]),
+		Amend:     strings.TrimSpace(files["amend"]),
+		Sign:      files["gpg-sign"] != "",
+	}, nil
+}
+
+func saveRebase(repoRoot string, state *rebaseState) error {
+	if err := os.MkdirAll(filepath.Join(common.MinigitDir(repoRoot), rebaseDir), 0755); err != nil {
+		return err
+	}
+	files := map[string]string{
+		"head-name": state.HeadName + "\n",
+		"orig-head": state.OrigHead + "\n",
+		"onto":      state.Onto + "\n",
+		"todo":      formatTodo(state.Todo),
+		"done":      formatTodo(state.Done),
+		"conflicts": strings.Join(state.Conflicts, "\n"),
+		"amend":     state.Amend,
+	}
+	if state.Sign {
+		files["gpg-sign"] = "\n"
+	}
+	for name, content := range files {
+		if err := os.WriteFile(rebaseStatePath(repoRoot, name), []byte(content), 0644); err != nil {
+			return fmt.Errorf("failed to write rebase state: %w", err)
+		}
+	}
+	return nil
+}
+
+func clearRebase(repoRoot string) error {
+	return os.RemoveAll(filepath.Join(common.MinigitDir(repoRoot), rebaseDir))
+}
+
+func requireRebase(repoRoot string) *rebaseState {
+	state, err := loadRebase(repoRoot)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if state == nil {
+		log.Fatal("no rebase in progress")
+	}
+	return state
+}
+
+// stopRebase saves the state and exits after a conflict
+func stopRebase(repoRoot string, state *rebaseState, step rebaseStep) {
+	if err := saveRebase(repoRoot, state); err != nil {
+		log.Fatal(err)
+	}
+	for _, path := range state.Conflicts {
+		fmt.Printf("CONFLICT (content): Merge conflict in %s\n", path)
+	}
+	fmt.Printf("error: could not apply %s... %s\n", step.Sha[:7], step.Rest)
+	fmt.Println("hint: fix the conflicts, add the files and run \"mini-git rebase --continue\"")
+	fmt.Println("hint: or use \"mini-git rebase --skip\" / \"mini-git rebase --abort\"")
+	os.Exit(1)
+}
+
+// advanceRebase marks the first todo step as done and saves the state
+func advanceRebase(repoRoot string, state *rebaseState) {
+	state.Done = append(state.Done, state.Todo[0])
+	state.Todo = state.Todo[1:]
+	if err := saveRebase(repoRoot, state); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// stopForEdit saves the state and exits so the commit of an edit step can be amended
+func stopForEdit(repoRoot string, state *rebaseState, step rebaseStep) {
+	headSha, err := common.GetParentSha(repoRoot)
+	if err != nil {
+		log.Fatal(err)
+	}
+	state.Amend = headSha
+	if err := saveRebase(repoRoot, state); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Stopped at %s... %s\n", step.Sha[:7], step.Rest)
+	fmt.Println("You can amend the commit now by adding changes, then run \"mini-git rebase --continue\"")
+	os.Exit(0)
+}
+
+// runExec runs the command of an exec step from the repository root
+func runExec(repoRoot string, command string) error {
+	fmt.Printf("Executing: %s\n", command)
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Dir = repoRoot
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd.Run()
+}
+
+// commitRebaseStep commits the index for one todo step, squash and fixup fold
+// the changes into the commit before them instead of adding a new one
+func commitRebaseStep(repoRoot string, state *rebaseState, step rebaseStep, index common.Index) error {
+	original, err := common.ReadCommit(repoRoot, step.Sha)
+	if err != nil {
+		return err
+	}
+	treeSha, err := buildTree(repoRoot, index)
+	if err != nil {
+		return fmt.Errorf("failed to build trees: %w", err)
+	}
+	headSha, err := common.GetParentSha(repoRoot)
+	if err != nil {
+		return err
+	}
+	head, err := common.ReadCommit(repoRoot, headSha)
+	if err != nil {
+		return err
+	}
+	meld := step.Action == "squash" || step.Action == "fixup"
+	if !meld && head.Tree == treeSha {
+		fmt.Printf("%s is empty after rebase, skipping it\n", step.Sha[:7])
+		return nil
+	}
+
+	commit := &common.Commit{Tree: treeSha, Parents: []string{headSha}, Message: original.Message, Author: original.Author}
+	switch step.Action {
+	case "reword":
+		if commit.Message, err = editMessage(repoRoot, original.Message); err != nil {
+			return err
+		}
+	case "squash":
+		commit.Parents, commit.Author = head.Parents, head.Author
+		if commit.Message, err = editMessage(repoRoot, head.Message+"\n\n"+original.Message); err != nil {
+			return err
+		}
+	case "fixup":
+		commit.Parents, commit.Author, commit.Message = head.Parents, head.Author, head.Message
+	}
+	commitSha, err := writeCommit(repoRoot, commit, state.Sign)
+	if err != nil {
+		return err
+	}
+	subject := firstLine(commit.Message)
+	if err := common.UpdateHead(repoRoot, commitSha, headSha, fmt.Sprintf("rebase (%s): %s", step.Action, subject)); err != nil {
+		return err
+	}
+	fmt.Printf("[%s %s] %s\n", currentBranchName(repoRoot), commitSha[:7], subject)
+	return nil
+}
+
+// amendEditStop folds the staged changes into the commit an edit step stopped at
+func amendEditStop(repoRoot string, state *rebaseState, index common.Index) error {
+	treeSha, err := buildTree(repoRoot, index)
+	if err != nil {
+		return fmt.Errorf("failed to build trees: %w", err)
+	}
+	headSha, err := common.GetParentSha(repoRoot)
+	if err != nil {
+		return err
+	}
+	head, err := common.ReadCommit(repoRoot, headSha)
+	if err != nil {
+		return err
+	}
+	if head.Tree == treeSha {
+		return nil
+	}
+	if headSha != state.Amend {
+		return fmt.Errorf("you have staged changes in your working tree, commit them first and then run \"mini-git rebase --continue\"")
+	}
+	commit := &common.Commit{Tree: treeSha, Parents: head.Parents, Author: head.Author, Message: head.Message}
+	commitSha, err := writeCommit(repoRoot, commit, state.Sign)
+	if err != nil {
+		return err
+	}
+	subject := firstLine(commit.Message)
+	if err := common.UpdateHead(repoRoot, commitSha, headSha, "rebase (amend): "+subject); err != nil {
+		return err
+	}
+	fmt.Printf("[%s %s] %s\n", currentBranchName(repoRoot), commitSha[:7], subject)
+	return nil
+}
+
+// runRebase executes the remaining steps and finishes the rebase
+func runRebase(repoRoot string, state *rebaseState) {
+	for len(state.Todo) > 0 {
+		step := state.Todo[0]
+		if step.Action == "exec" {
+			advanceRebase(repoRoot, state)
+			if err := runExec(repoRoot, step.Rest); err != nil {
+				fmt.Printf("warning: execution failed: %s\n", step.Rest)
+				fmt.Println("hint: fix the problem and run \"mini-git rebase --continue\"")
+				os.Exit(1)
+			}
+			continue
+		}
+		if step.Action != "drop" {
+			conflicts, err := applyReplay(repoRoot, "rebase", step.Sha)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if len(conflicts) > 0 {
+				state.Conflicts = conflicts
+				stopRebase(repoRoot, state, step)
+			}
+			index, err := common.ReadIndex(repoRoot)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if err := commitRebaseStep(repoRoot, state, step, index); err != nil {
+				log.Fatal(err)
+			}
+		}
+		advanceRebase(repoRoot, state)
+		if step.Action == "edit" {
+			stopForEdit(repoRoot, state, step)
+		}
+	}
+	finishRebase(repoRoot, state)
+}
+
+// finishRebase moves the branch to the rebased commits and puts HEAD back on it
+func finishRebase(repoRoot string, state *rebaseState) {
+	newHead, err := common.GetParentSha(repoRoot)
+	if err != nil {
+		log.Fatal(err)
+	}
+	reason := fmt.Sprintf("rebase finished: %s onto %s", state.HeadName, state.Onto)
+	// the branch may have moved since the rebase started, from another
+	// worktree, and those commits are not in the rebased history
+	transaction := common.NewRefTransaction(repoRoot)
+	transaction.Update(state.HeadName, newHead, state.OrigHead, reason)
+	if err := transaction.Commit(); err != nil {
+		var conflict *common.RefConflictError
+		if errors.As(err, &conflict) {
+			log.Fatalf("%s has moved since the rebase started, not overwriting it, the rebased commits are at HEAD (%s)", state.HeadName, newHead)
+		}
+		log.Fatal(err)
+	}
+	if err := common.SetHeadRef(repoRoot, state.HeadName, "rebase finished: returning to "+state.HeadName); err != nil {
+		log.Fatal(err)
+	}
+	if err := clearRebase(repoRoot); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Successfully rebased and updated %s.\n", state.HeadName)
+}
+
+// planRebase works out the branch being rebased and the commits to replay onto onto
+func planRebase(repoRoot string, upstream string, onto string) *rebaseState {
+	if inProgress, err := loadRebase(repoRoot); err != nil {
+		log.Fatal(err)
+	} else if inProgress != nil {
+		log.Fatal("a rebase is already in progress, use --continue, --skip or --abort")
+	}
+	if sequencer, err := loadSequencer(repoRoot); err != nil {
+		log.Fatal(err)
+	} else if sequencer != nil {
+		log.Fatalf("a %s is in progress, finish it first", sequencer.Action)
+	}
+	headName, err := common.GetHeadRef(repoRoot)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if headName == common.HEAD {
+		log.Fatal("cannot rebase a detached HEAD, checkout a branch first")
+	}
+	if err := ensureClean(repoRoot, "rebase"); err != nil {
+		log.Fatal(err)
+	}
+	headSha, err := common.GetParentSha(repoRoot)
+	if err != nil || headSha == "" {
+		log.Fatal("the current branch has no commits to rebase")
+	}
+	upstreamSha, err := common.ResolveCommit(repoRoot, upstream)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ontoSha := upstreamSha
+	if onto != "" {
+		if ontoSha, err = common.ResolveCommit(repoRoot, onto); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	commits, err := commitsBetween(repoRoot, upstreamSha, headSha)
+	if err != nil {
+		log.Fatal(err)
+	}
+	state := &rebaseState{HeadName: headName, OrigHead: headSha, Onto: ontoSha}
+	for _, sha := range commits {
+		commit, err := common.ReadCommit(repoRoot, sha)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(commit.Parents) > 1 { // like git, merges are dropped from a rebase
+			continue
+		}
+		state.Todo = append(state.Todo, rebaseStep{Action: "pick", Sha: sha, Rest: firstLine(commit.Message)})
+	}
+	return state
+}
+
+// alreadyOnto reports whether the commits to replay already sit directly on onto
+func alreadyOnto(repoRoot string, state *rebaseState) bool {
+	if state.Onto == state.OrigHead {
+		return true
+	}
+	if len(state.Todo) == 0 {
+		return false
+	}
+	first, err := common.ReadCommit(repoRoot, state.Todo[0].Sha)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return len(first.Parents) == 1 && first.Parents[0] == state.Onto
+}
+
+// squashTarget splits a "fixup! <subject>" or "squash! <subject>" subject into
+// the action and the subject or sha it refers to
+func squashTarget(subject string) (string, string, bool) {
+	for _, action := range []string{"fixup", "squash"} {
+		if target, ok := strings.CutPrefix(subject, action+"! "); ok {
+			for { // "fixup! fixup! x" belongs to x as well
+				trimmed := strings.TrimPrefix(strings.TrimPrefix(target, "fixup! "), "squash! ")
+				if trimmed == target {
+					break
+				}
+				target = trimmed
+			}
+			return action, target, true
+		}
+	}
+	return "", "", false
+}
+
+// autosquash moves fixup! and squash! commits right after the commit they refer
+// to and turns them into fixup and squash steps, the rest keep their order
+func autosquash(steps []rebaseStep) []rebaseStep {
+	var ordered []rebaseStep
+	moved := make([]bool, len(steps))
+	for i, step := range steps {
+		if moved[i] {
+			continue
+		}
+		ordered = append(ordered, step)
+		for j := i + 1; j < len(steps); j++ {
+			action, target, ok := squashTarget(steps[j].Rest)
+			if !ok || moved[j] {
+				continue
+			}
+			if target == step.Rest || (len(target) >= 4 && strings.HasPrefix(step.Sha, target)) {
+				moved[j] = true
+				ordered = append(ordered, rebaseStep{Action: action, Sha: steps[j].Sha, Rest: steps[j].Rest})
+			}
+		}
+	}
+	return ordered
+}
+
+// checkTodo expands the commits of an edited todo list and rejects a squash or
+// fixup that has no commit before it to meld into
+func checkTodo(repoRoot string, steps []rebaseStep) error {
+	picked := false
+	for i, step := range steps {
+		if step.Action == "exec" {
+			continue
+		}
+		sha, err := common.ResolveCommit(repoRoot, step.Sha)
+		if err != nil {
+			return err
+		}
+		if _, err := common.ReadCommit(repoRoot, sha); err != nil {
+			return err
+		}
+		steps[i].Sha = sha
+		switch step.Action {
+		case "squash", "fixup":
+			if !picked {
+				return fmt.Errorf("cannot '%s' without a previous commit", step.Action)
+			}
+		case "drop":
+		default:
+			picked = true
+		}
+	}
+	return nil
+}
+
+// editTodo opens the todo list in the editor and reads back the steps to run
+func editTodo(repoRoot string, state *rebaseState) error {
+	if err := saveRebase(repoRoot, state); err != nil {
+		return err
+	}
+	path := rebaseStatePath(repoRoot, "todo")
+	header := fmt.Sprintf("\n# Rebase %s..%s onto %s (%d commands)\n", state.Onto[:7], state.OrigHead[:7], state.Onto[:7], len(state.Todo))
+	if err := os.WriteFile(path, []byte(formatTodo(state.Todo)+header+todoHelp), 0644); err != nil {
+		return fmt.Errorf("failed to write rebase state: %w", err)
+	}
+	if err := runEditor(path); err != nil {
+		return err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if state.Todo, err = parseTodo(string(content)); err != nil {
+		return err
+	}
+	return checkTodo(repoRoot, state.Todo)
+}
+
+// startRebase detaches HEAD at the new base and runs the todo list, unless
+// force is set nothing happens when the branch already sits on onto
+func startRebase(repoRoot string, state *rebaseState, force bool) {
+	if !force && alreadyOnto(repoRoot, state) {
+		fmt.Printf("Current branch %s is up to date.\n", strings.TrimPrefix(state.HeadName, "refs/heads/"))
+		return
+	}
+	if err := saveRebase(repoRoot, state); err != nil {
+		log.Fatal(err)
+	}
+	oldIndex, err := commitIndex(repoRoot, state.OrigHead)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ontoIndex, err := commitIndex(repoRoot, state.Onto)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := updateWorktree(repoRoot, oldIndex, ontoIndex); err != nil {
+		log.Fatal(err)
+	}
+	if err := common.WriteIndex(repoRoot, ontoIndex); err != nil {
+		log.Fatal(err)
+	}
+	if err := common.SetHeadDetached(repoRoot, state.Onto, "rebase: checkout "+state.Onto); err != nil {
+		log.Fatal(err)
+	}
+	runRebase(repoRoot, state)
+}
+
+// resumeRebase handles --continue, --skip and --abort
+func resumeRebase(repoRoot string, mode string) {
+	state := requireRebase(repoRoot)
+	switch mode {
+	case "continue":
+		unresolved, err := unresolvedConflicts(repoRoot, state.Conflicts)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(unresolved) > 0 {
+			log.Fatalf("you still have unresolved conflicts in %s, fix them and add the files", strings.Join(unresolved, ", "))
+		}
+		index, err := common.ReadIndex(repoRoot)
+		if err != nil {
+			log.Fatal(err)
+		}
+		dropMissingFromIndex(repoRoot, index)
+		if err := common.WriteIndex(repoRoot, index); err != nil {
+			log.Fatal(err)
+		}
+		if state.Amend != "" {
+			if err := amendEditStop(repoRoot, state, index); err != nil {
+				log.Fatal(err)
+			}
+		} else if len(state.Conflicts) > 0 && len(state.Todo) > 0 {
+			step := state.Todo[0]
+			if err := commitRebaseStep(repoRoot, state, step, index); err != nil {
+				log.Fatal(err)
+			}
+			advanceRebase(repoRoot, state)
+			if step.Action == "edit" {
+				state.Conflicts = nil
+				stopForEdit(repoRoot, state, step)
+			}
+		}
+	case "skip":
+		headSha, err := common.GetParentSha(repoRoot)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := resetHard(repoRoot, headSha, state.Conflicts); err != nil {
+			log.Fatal(err)
+		}
+		if len(state.Conflicts) > 0 && len(state.Todo) > 0 {
+			state.Todo = state.Todo[1:]
+		}
+	case "abort":
+		if err := resetHard(repoRoot, state.OrigHead, state.Conflicts); err != nil {
+			log.Fatal(err)
+		}
+		if err := common.SetHeadRef(repoRoot, state.HeadName, "rebase (abort): returning to "+state.HeadName); err != nil {
+			log.Fatal(err)
+		}
+		if err := clearRebase(repoRoot); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	state.Conflicts = nil
+	state.Amend = ""
+	if err := saveRebase(repoRoot, state); err != nil {
+		log.Fatal(err)
+	}
+	runRebase(repoRoot, state)
+}
+
+func RebaseCommand(cmd *cobra.Command, args []string) {
+	repoPath, err := common.FindRepoRoot()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, mode := range []string{"continue", "skip", "abort"} {
+		if set, _ := cmd.Flags().GetBool(mode); set {
+			resumeRebase(repoPath, mode)
+			return
+		}
+	}
+	if len(args) != 1 {
+		log.Fatal("usage: mini-git rebase [-i] [--autosquash] <upstream> [--onto <newbase>]")
+	}
+	onto, _ := cmd.Flags().GetString("onto")
+	interactive, _ := cmd.Flags().GetBool("interactive")
+	squash, _ := cmd.Flags().GetBool("autosquash")
+	state := planRebase(repoPath, args[0], onto)
+	if state.Sign, err = wantSignature(repoPath, cmd, "gpg-sign"); err != nil {
+		log.Fatal(err)
+	}
+	if squash {
+		state.Todo = autosquash(state.Todo)
+	}
+	if interactive {
+		if err := editTodo(repoPath, state); err != nil {
+			clearRebase(repoPath)
+			log.Fatal(err)
+		}
+		if len(state.Todo) == 0 {
+			if err := clearRebase(repoPath); err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println("Nothing to do")
+			return
+		}
+	}
+	startRebase(repoPath, state, interactive || squash)
+}
@@ -0,0 +1,94 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/hanzala211/mini-git/common"
+	"github.com/spf13/cobra"
+)
+
+const DefaultReflogExpiry = "90.days.ago"
+
+func showReflog(repoRoot string, name string) {
+	refName, err := common.ExpandRefName(repoRoot, name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	entries, err := common.ReadReflog(repoRoot, refName)
+	if err != nil {
+		log.Fatalf("failed to read reflog: %v", err)
+	}
+	for i, entry := range entries {
+		fmt.Printf("%s %s@{%d}: %s\n", entry.NewSha[:7], name, i, entry.Reason)
+	}
+}
+
+func expireReflogs(repoRoot string, refNames []string, expiry string) {
+	cutoff, err := parseExpiry(expiry, time.Now())
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, refName := range refNames {
+		entries, err := common.ReadReflog(repoRoot, refName)
+		if err != nil {
+			log.Fatalf("failed to read reflog for %s: %v", refName, err)
+		}
+		var kept []common.ReflogEntry
+		for _, entry := range entries {
+			if !entry.Time().Before(cutoff) {
+				kept = append(kept, entry)
+			}
+		}
+		if len(kept) == len(entries) {
+			continue
+		}
+		if err := common.WriteReflog(repoRoot, refName, kept); err != nil {
+			log.Fatalf("failed to write reflog for %s: %v", refName, err)
+		}
+		fmt.Printf("expired %d entries from %s\n", len(entries)-len(kept), refName)
+	}
+}
+
+func ReflogCommand(cmd *cobra.Command, args []string) {
+	repoPath, err := common.FindRepoRoot()
+	if err != nil {
+		log.Fatal(err)
+	}
+	action := "show"
+	if len(args) > 0 && (args[0] == "show" || args[0] == "expire") {
+		action, args = args[0], args[1:]
+	}
+
+	switch action {
+	case "show":
+		name := common.HEAD
+		if len(args) > 0 {
+			name = args[0]
+		}
+		showReflog(repoPath, name)
+	case "expire":
+		expiry, _ := cmd.Flags().GetString("expire")
+		all, _ := cmd.Flags().GetBool("all")
+		var refNames []string
+		if all {
+			refNames, err = common.ListReflogs(repoPath)
+			if err != nil {
+				log.Fatalf("failed to list reflogs: %v", err)
+			}
+		}
+		for _, name := range args {
+			refName, err := common.ExpandRefName(repoPath, strings.TrimSpace(name))
+			if err != nil {
+				log.Fatal(err)
+			}
+			refNames = append(refNames, refName)
+		}
+		if len(refNames) == 0 {
+			log.Fatal("nothing to expire, pass a ref or --all")
+		}
+		expireReflogs(repoPath, refNames, expiry)
+	}
+}
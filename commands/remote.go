@@ -0,0 +1,101 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hanzala211/mini-git/common"
+	"github.com/spf13/cobra"
+)
+
+func removeRemote(repoRoot string, config *common.Config, name string) error {
+	delete(config.Remotes, name)
+	for branch, upstream := range config.Branches {
+		if upstream.Remote == name {
+			delete(config.Branches, branch)
+		}
+	}
+	refs, err := common.ListRefs(repoRoot)
+	if err != nil {
+		return err
+	}
+	trackingPrefix := fmt.Sprintf("%s/%s/%s/", common.RefsDir, common.RemotesDir, name)
+	for refName := range refs {
+		if strings.HasPrefix(refName, trackingPrefix) {
+			if err := common.DeleteRef(repoRoot, refName); err != nil {
+				return err
+			}
+		}
+	}
+	if err := os.RemoveAll(filepath.Join(common.CommonDir(repoRoot), common.LogsDir, filepath.FromSlash(trackingPrefix))); err != nil {
+		return err
+	}
+	return os.RemoveAll(filepath.Join(common.CommonDir(repoRoot), filepath.FromSlash(trackingPrefix)))
+}
+
+func RemoteCommand(cmd *cobra.Command, args []string) {
+	repoPath, err := common.FindRepoRoot()
+	if err != nil {
+		log.Fatal(err)
+	}
+	config, err := common.ReadConfig(repoPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	action := "list"
+	if len(args) > 0 {
+		action, args = args[0], args[1:]
+	}
+
+	switch action {
+	case "list":
+		verbose, _ := cmd.Flags().GetBool("verbose")
+		names := make([]string, 0, len(config.Remotes))
+		for name := range config.Remotes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if verbose {
+				fmt.Printf("%s\t%s\n", name, config.Remotes[name].URL)
+			} else {
+				fmt.Println(name)
+			}
+		}
+		return
+	case "add":
+		if len(args) != 2 {
+			log.Fatal("usage: mini-git remote add <name> <url>")
+		}
+		if _, ok := config.Remotes[args[0]]; ok {
+			log.Fatalf("remote %s already exists", args[0])
+		}
+		if strings.ContainsAny(args[0], "/ ") {
+			log.Fatalf("'%s' is not a valid remote name", args[0])
+		}
+		url, err := normalizeRemoteURL(args[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+		config.Remotes[args[0]] = common.RemoteConfig{URL: url}
+	case "remove", "rm":
+		if len(args) != 1 {
+			log.Fatal("usage: mini-git remote remove <name>")
+		}
+		if _, ok := config.Remotes[args[0]]; !ok {
+			log.Fatalf("no such remote '%s'", args[0])
+		}
+		if err := removeRemote(repoPath, config, args[0]); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		log.Fatalf("unknown remote subcommand %q", action)
+	}
+	if err := common.WriteConfig(repoPath, config); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,29 @@
+package commands
+
+import (
+	"log"
+
+	"github.com/hanzala211/mini-git/common"
+	"github.com/spf13/cobra"
+)
+
+func RevertCommand(cmd *cobra.Command, args []string) {
+	repoPath, err := common.FindRepoRoot()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, mode := range []string{"continue", "skip", "abort"} {
+		if set, _ := cmd.Flags().GetBool(mode); set {
+			resumeSequencer(repoPath, "revert", mode)
+			return
+		}
+	}
+	if len(args) == 0 {
+		log.Fatal("nothing to revert, pass one or more commits")
+	}
+	sign, err := wantSignature(repoPath, cmd, "gpg-sign")
+	if err != nil {
+		log.Fatal(err)
+	}
+	startSequencer(repoPath, "revert", args, sign)
+}
@@ -0,0 +1,299 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hanzala211/mini-git/common"
+)
+
+// the sequencer replays a list of commits one at a time for cherry-pick and
+// revert (rebase reuses its replay steps but keeps its own state), its state
+// is kept in .minigit/sequencer.json so a conflicted run can be resumed with
+// --continue, --skip or --abort
+const sequencerFile = "sequencer.json"
+
+type sequencerState struct {
+	Action    string   `json:"action"`
+	Branch    string   `json:"branch"`    // the ref HEAD was on when the operation started
+	Head      string   `json:"head"`      // what that ref pointed to, restored by --abort
+	Todo      []string `json:"todo"`      // commits still to replay, the first one is in progress
+	Conflicts []string `json:"conflicts"` // paths that conflicted while replaying Todo[0]
+	Sign      bool     `json:"sign"`      // whether the new commits are signed
+}
+
+func sequencerPath(repoRoot string) string {
+	return filepath.Join(common.MinigitDir(repoRoot), sequencerFile)
+}
+
+// loadSequencer returns nil when no cherry-pick or revert is in progress
+func loadSequencer(repoRoot string) (*sequencerState, error) {
+	content, err := os.ReadFile(sequencerPath(repoRoot))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var state sequencerState
+	if err := json.Unmarshal(content, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", sequencerFile, err)
+	}
+	return &state, nil
+}
+
+func saveSequencer(repoRoot string, state *sequencerState) error {
+	content, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(sequencerPath(repoRoot), content, 0644)
+}
+
+func clearSequencer(repoRoot string) error {
+	if err := os.Remove(sequencerPath(repoRoot)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// replayedCommit returns the message and author the new commit for sha gets
+func replayedCommit(action string, sha string, commit *common.Commit) (string, string) {
+	switch action {
+	case "cherry-pick":
+		return commit.Message, commit.Author
+	case "revert": // the revert is authored by whoever runs it, WriteCommit fills that in
+		return fmt.Sprintf("Revert \"%s\"\n\nThis reverts commit %s.", firstLine(commit.Message), sha), ""
+	}
+	log.Fatalf("unknown sequencer action %q", action)
+	return "", ""
+}
+
+// applyReplay merges the changes of one commit into the working tree and index,
+// returning the conflicted paths
+func applyReplay(repoRoot string, action string, sha string) ([]string, error) {
+	commit, err := common.ReadCommit(repoRoot, sha)
+	if err != nil {
+		return nil, err
+	}
+	if len(commit.Parents) > 1 {
+		return nil, fmt.Errorf("commit %s is a merge, replaying merges is not supported", sha[:7])
+	}
+	parentSha := ""
+	if len(commit.Parents) == 1 {
+		parentSha = commit.Parents[0]
+	}
+	parent, err := commitIndex(repoRoot, parentSha)
+	if err != nil {
+		return nil, err
+	}
+	changed, err := treeIndex(repoRoot, commit.Tree)
+	if err != nil {
+		return nil, err
+	}
+	headSha, err := common.GetParentSha(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	head, err := commitIndex(repoRoot, headSha)
+	if err != nil {
+		return nil, err
+	}
+
+	base, theirs := parent, changed
+	if action == "revert" { // a revert is the commit applied backwards
+		base, theirs = changed, parent
+	}
+	label := fmt.Sprintf("%s (%s)", sha[:7], firstLine(commit.Message))
+	result, err := mergeIndexes(repoRoot, base, head, theirs, "HEAD", label)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyMergeResult(repoRoot, head, result); err != nil {
+		return nil, err
+	}
+	return result.Conflicts, nil
+}
+
+// commitReplay commits the index as the replayed version of sha, changes that
+// turn out to be empty are skipped
+func commitReplay(repoRoot string, action string, sha string, index common.Index, sign bool) error {
+	original, err := common.ReadCommit(repoRoot, sha)
+	if err != nil {
+		return err
+	}
+	treeSha, err := buildTree(repoRoot, index)
+	if err != nil {
+		return fmt.Errorf("failed to build trees: %w", err)
+	}
+	headSha, err := common.GetParentSha(repoRoot)
+	if err != nil {
+		return err
+	}
+	commit := &common.Commit{Tree: treeSha}
+	if headSha != "" {
+		head, err := common.ReadCommit(repoRoot, headSha)
+		if err != nil {
+			return err
+		}
+		if head.Tree == treeSha {
+			fmt.Printf("%s is empty after %s, skipping it\n", sha[:7], action)
+			return nil
+		}
+		commit.Parents = []string{headSha}
+	}
+	commit.Message, commit.Author = replayedCommit(action, sha, original)
+	commitSha, err := writeCommit(repoRoot, commit, sign)
+	if err != nil {
+		return err
+	}
+	subject := firstLine(commit.Message)
+	if err := common.UpdateHead(repoRoot, commitSha, headSha, action+": "+subject); err != nil {
+		return err
+	}
+	fmt.Printf("[%s %s] %s\n", currentBranchName(repoRoot), commitSha[:7], subject)
+	return nil
+}
+
+// runSequencer replays the remaining commits, stopping at the first conflict
+func runSequencer(repoRoot string, state *sequencerState) {
+	for len(state.Todo) > 0 {
+		sha := state.Todo[0]
+		conflicts, err := applyReplay(repoRoot, state.Action, sha)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(conflicts) > 0 {
+			state.Conflicts = conflicts
+			if err := saveSequencer(repoRoot, state); err != nil {
+				log.Fatal(err)
+			}
+			for _, path := range conflicts {
+				fmt.Printf("CONFLICT (content): Merge conflict in %s\n", path)
+			}
+			fmt.Printf("error: could not %s %s\n", state.Action, sha[:7])
+			fmt.Printf("hint: fix the conflicts, add the files and run \"mini-git %s --continue\"\n", state.Action)
+			fmt.Printf("hint: or use \"mini-git %s --skip\" / \"mini-git %s --abort\"\n", state.Action, state.Action)
+			os.Exit(1)
+		}
+		index, err := common.ReadIndex(repoRoot)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := commitReplay(repoRoot, state.Action, sha, index, state.Sign); err != nil {
+			log.Fatal(err)
+		}
+		state.Todo = state.Todo[1:]
+		if err := saveSequencer(repoRoot, state); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := clearSequencer(repoRoot); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// startSequencer resolves the revisions and replays them onto HEAD, sign is
+// kept in the state so commits made by --continue are signed the same way
+func startSequencer(repoRoot string, action string, revs []string, sign bool) {
+	state, err := loadSequencer(repoRoot)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if state != nil {
+		log.Fatalf("a %s is already in progress, use --continue, --skip or --abort", state.Action)
+	}
+	if err := ensureClean(repoRoot, action); err != nil {
+		log.Fatal(err)
+	}
+	headRef, err := common.GetHeadRef(repoRoot)
+	if err != nil {
+		log.Fatal(err)
+	}
+	headSha, err := common.GetParentSha(repoRoot)
+	if err != nil {
+		log.Fatal(err)
+	}
+	state = &sequencerState{Action: action, Branch: headRef, Head: headSha, Sign: sign}
+	for _, rev := range revs {
+		sha, err := common.ResolveCommit(repoRoot, rev)
+		if err != nil {
+			log.Fatal(err)
+		}
+		state.Todo = append(state.Todo, sha)
+	}
+	if err := saveSequencer(repoRoot, state); err != nil {
+		log.Fatal(err)
+	}
+	runSequencer(repoRoot, state)
+}
+
+func requireSequencer(repoRoot string, action string) *sequencerState {
+	state, err := loadSequencer(repoRoot)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if state == nil || state.Action != action {
+		log.Fatalf("no %s in progress", action)
+	}
+	return state
+}
+
+// resumeSequencer handles --continue, --skip and --abort
+func resumeSequencer(repoRoot string, action string, mode string) {
+	state := requireSequencer(repoRoot, action)
+	switch mode {
+	case "continue":
+		unresolved, err := unresolvedConflicts(repoRoot, state.Conflicts)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(unresolved) > 0 {
+			log.Fatalf("you still have unresolved conflicts in %s, fix them and add the files", strings.Join(unresolved, ", "))
+		}
+		index, err := common.ReadIndex(repoRoot)
+		if err != nil {
+			log.Fatal(err)
+		}
+		dropMissingFromIndex(repoRoot, index)
+		if err := common.WriteIndex(repoRoot, index); err != nil {
+			log.Fatal(err)
+		}
+		if len(state.Todo) > 0 {
+			if err := commitReplay(repoRoot, action, state.Todo[0], index, state.Sign); err != nil {
+				log.Fatal(err)
+			}
+			state.Todo = state.Todo[1:]
+		}
+	case "skip":
+		headSha, err := common.GetParentSha(repoRoot)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := resetHard(repoRoot, headSha, state.Conflicts); err != nil {
+			log.Fatal(err)
+		}
+		if len(state.Todo) > 0 {
+			state.Todo = state.Todo[1:]
+		}
+	case "abort":
+		if err := common.UpdateRef(repoRoot, state.Branch, state.Head, action+": abort"); err != nil {
+			log.Fatal(err)
+		}
+		if err := resetHard(repoRoot, state.Head, state.Conflicts); err != nil {
+			log.Fatal(err)
+		}
+		if err := clearSequencer(repoRoot); err != nil {
+			log.Fatal(err)
+		}
+		return
+	default:
+		log.Fatalf("unknown sequencer mode %q", mode)
+	}
+	state.Conflicts = nil
+	runSequencer(repoRoot, state)
+}
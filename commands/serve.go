@@ -0,0 +1,196 @@
+package commands
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/hanzala211/mini-git/common"
+	"github.com/spf13/cobra"
+)
+
+// the endpoints every served repository has, below its own url
+const (
+	refsEndpoint  = "/info/refs"
+	fetchEndpoint = "/fetch"
+	pushEndpoint  = "/push"
+)
+
+// fetchRequest is what a client posts to the fetch endpoint
+type fetchRequest struct {
+	Wants []string `json:"wants"`
+	Haves []string `json:"haves"`
+}
+
+// repoServer serves the repository at base, or every repository below it at
+// a url named after its directory
+type repoServer struct {
+	base string
+	mu   sync.Mutex // pushes are applied one at a time
+}
+
+func newRepoServer(base string) *repoServer {
+	return &repoServer{base: base}
+}
+
+// repoRoot maps the part of a url before the endpoint to a repository on disk
+func (s *repoServer) repoRoot(urlPath string) (string, error) {
+	root := filepath.Join(s.base, filepath.FromSlash(path.Clean("/"+urlPath)))
+	if info, err := os.Stat(filepath.Join(root, common.RootDir)); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("no repository at %s", urlPath)
+	}
+	return root, nil
+}
+
+func (s *repoServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	for _, endpoint := range []string{refsEndpoint, fetchEndpoint, pushEndpoint} {
+		repoPath, ok := strings.CutSuffix(r.URL.Path, endpoint)
+		if !ok {
+			continue
+		}
+		root, err := s.repoRoot(repoPath)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		method := http.MethodPost
+		if endpoint == refsEndpoint {
+			method = http.MethodGet
+		}
+		if r.Method != method {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		switch endpoint {
+		case refsEndpoint:
+			s.serveRefs(w, root)
+		case fetchEndpoint:
+			s.serveFetch(w, r, root)
+		case pushEndpoint:
+			s.servePush(w, r, root)
+		}
+		return
+	}
+	http.NotFound(w, r)
+}
+
+func (s *repoServer) serveRefs(w http.ResponseWriter, root string) {
+	ads, err := advertiseRefs(root)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ads)
+}
+
+// serveFetch answers the wants with every object the client is missing, the
+// haves the server knows tell it which history the client already has
+func (s *repoServer) serveFetch(w http.ResponseWriter, r *http.Request, root string) {
+	var request fetchRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid fetch request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkWants(root, request.Wants); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	known, err := reachableObjects(root, request.Haves)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	shas, err := objectsToSend(root, request.Wants, func(sha string) bool { return known[sha] })
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if err := writeObjectStream(w, root, shas); err != nil {
+		log.Printf("fetch from %s: %v", r.RemoteAddr, err)
+	}
+}
+
+// checkWants makes sure a fetch only asks for what the advertised refs reach,
+// an object that exists but is not on any of them is not handed out. Wants are
+// usually the tips themselves, so the history is only walked when one is not.
+func checkWants(root string, wants []string) error {
+	ads, err := advertiseRefs(root)
+	if err != nil {
+		return err
+	}
+	tips := make(map[string]bool, len(ads.Refs))
+	for _, sha := range ads.Refs {
+		tips[sha] = true
+	}
+	var reachable map[string]bool
+	for _, want := range wants {
+		if tips[want] {
+			continue
+		}
+		if reachable == nil {
+			if reachable, err = reachableObjects(root, slices.Collect(maps.Keys(tips))); err != nil {
+				return err
+			}
+		}
+		if !reachable[want] {
+			return fmt.Errorf("not our ref %s", want)
+		}
+	}
+	return nil
+}
+
+// servePush reads the updates on the first line of the body and the objects
+// they need after it, the refs only move once every object is stored
+func (s *repoServer) servePush(w http.ResponseWriter, r *http.Request, root string) {
+	reader := bufio.NewReader(r.Body)
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		http.Error(w, "invalid push request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	var updates []refUpdate
+	if err := json.Unmarshal(line, &updates); err != nil {
+		http.Error(w, "invalid push request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkUpdates(root, updates); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if _, err := readObjectStream(reader, root); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := receiveUpdates(root, updates, r.RemoteAddr); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func ServeCommand(cmd *cobra.Command, args []string) {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	base, err := filepath.Abs(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	addr, _ := cmd.Flags().GetString("addr")
+	fmt.Printf("Serving %s on http://%s/\n", base, addr)
+	log.Fatal(http.ListenAndServe(addr, newRepoServer(base)))
+}
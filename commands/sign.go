@@ -0,0 +1,238 @@
+package commands
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hanzala211/mini-git/common"
+	"github.com/spf13/cobra"
+)
+
+const allowedSignersFile = "allowed_signers"
+
+// signatureCheck is the outcome of checking the signature of a commit or tag
+type signatureCheck struct {
+	signed    bool
+	key       ed25519.PublicKey // the key that made the signature, nil when it could not be read
+	err       error             // why the signature is bad
+	principal string            // the allowed signer it matched, "" when the key is not trusted
+	untrusted string            // why the key is not trusted
+}
+
+func (c signatureCheck) good() bool {
+	return c.signed && c.err == nil && c.principal != ""
+}
+
+func (c signatureCheck) String() string {
+	switch {
+	case !c.signed:
+		return "No signature"
+	case c.key == nil:
+		return fmt.Sprintf("Could not verify signature: %v", c.err)
+	case c.err != nil:
+		return fmt.Sprintf("BAD signature with ED25519 key %s: %v", common.Fingerprint(c.key), c.err)
+	case c.principal == "":
+		return fmt.Sprintf("Good %q signature with ED25519 key %s, but %s", common.SignatureNamespace, common.Fingerprint(c.key), c.untrusted)
+	}
+	return fmt.Sprintf("Good %q signature for %s with ED25519 key %s", common.SignatureNamespace, c.principal, common.Fingerprint(c.key))
+}
+
+// identityEmail returns the email of a "Name <email>" identity
+func identityEmail(identity string) string {
+	start, end := strings.LastIndexByte(identity, '<'), strings.LastIndexByte(identity, '>')
+	if start == -1 || end < start {
+		return ""
+	}
+	return identity[start+1 : end]
+}
+
+// configPath makes a path from the config absolute, relative ones are relative to the working tree
+func configPath(repoRoot string, path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(repoRoot, path)
+}
+
+func allowedSignersPath(repoRoot string, config *common.Config) string {
+	if config.Signing != nil && config.Signing.AllowedSigners != "" {
+		return configPath(repoRoot, config.Signing.AllowedSigners)
+	}
+	return filepath.Join(common.CommonDir(repoRoot), allowedSignersFile)
+}
+
+// checkSignature verifies signature over payload and looks the key up in the
+// allowed signers under the email of identity, the committer or tagger
+func checkSignature(repoRoot string, signature string, payload []byte, identity string) signatureCheck {
+	check := signatureCheck{signed: signature != ""}
+	if !check.signed {
+		return check
+	}
+	if check.key, check.err = common.VerifySignature(signature, payload); check.err != nil {
+		return check
+	}
+	config, err := common.ReadConfig(repoRoot)
+	if err != nil {
+		check.untrusted = err.Error()
+		return check
+	}
+	signers, err := common.ReadAllowedSigners(allowedSignersPath(repoRoot, config))
+	if err != nil {
+		check.untrusted = err.Error()
+		return check
+	}
+	email := identityEmail(identity)
+	principal, listed := common.FindPrincipal(signers, check.key, email)
+	switch {
+	case principal != "":
+		check.principal = principal
+	case listed:
+		check.untrusted = fmt.Sprintf("the key is not allowed to sign for %s", email)
+	default:
+		check.untrusted = "the key is not in the allowed signers"
+	}
+	return check
+}
+
+// signingKey loads the key named by signing.key in the config
+func signingKey(repoRoot string) (ed25519.PrivateKey, error) {
+	config, err := common.ReadConfig(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	if config.Signing == nil || config.Signing.Key == "" {
+		return nil, fmt.Errorf("no signing key configured, set signing.key in %s", filepath.Join(common.RootDir, common.ConfigFile))
+	}
+	return common.LoadSigningKey(configPath(repoRoot, config.Signing.Key))
+}
+
+// wantSignature decides whether to sign: the flag given on the command line
+// wins, otherwise signing.sign from the config
+func wantSignature(repoRoot string, cmd *cobra.Command, flag string) (bool, error) {
+	if cmd.Flags().Changed(flag) {
+		return cmd.Flags().GetBool(flag)
+	}
+	if noSign, _ := cmd.Flags().GetBool("no-" + flag); noSign {
+		return false, nil
+	}
+	config, err := common.ReadConfig(repoRoot)
+	if err != nil {
+		return false, err
+	}
+	return config.Signing != nil && config.Signing.Sign, nil
+}
+
+// checkCommitSignature verifies a stored commit against its bytes as stored,
+// not a re-serialization that would drop headers the parser does not know
+func checkCommitSignature(repoRoot string, sha string) (signatureCheck, error) {
+	objType, data, err := common.ReadObjectWithType(repoRoot, sha)
+	if err != nil {
+		return signatureCheck{}, err
+	}
+	if objType != common.CommitFile {
+		return signatureCheck{}, fmt.Errorf("object %s is a %s, not a commit", sha, objType)
+	}
+	commit, err := common.ParseCommit(data)
+	if err != nil {
+		return signatureCheck{}, err
+	}
+	return checkSignature(repoRoot, commit.Signature, common.SignedCommitPayload(data), commit.AuthorName()), nil
+}
+
+func signCommit(repoRoot string, commit *common.Commit) error {
+	key, err := signingKey(repoRoot)
+	if err != nil {
+		return err
+	}
+	commit.SetDefaults() // the author and time are part of what gets signed
+	commit.Signature = common.Sign(key, commit.Payload())
+	return nil
+}
+
+// writeCommit stores a new commit, signed first when sign is set. Every command
+// that makes commits goes through here, so signing.sign covers all of them.
+func writeCommit(repoRoot string, commit *common.Commit, sign bool) (string, error) {
+	if sign {
+		if err := signCommit(repoRoot, commit); err != nil {
+			return "", fmt.Errorf("failed to sign the commit: %w", err)
+		}
+	}
+	commitSha, err := common.WriteCommit(repoRoot, commit)
+	if err != nil {
+		return "", fmt.Errorf("failed to write commit object: %w", err)
+	}
+	return commitSha, nil
+}
+
+func signTag(repoRoot string, tag *common.Tag) error {
+	key, err := signingKey(repoRoot)
+	if err != nil {
+		return err
+	}
+	tag.SetDefaults()
+	tag.Signature = common.Sign(key, tag.Payload())
+	return nil
+}
+
+func VerifyCommitCommand(cmd *cobra.Command, args []string) {
+	repoPath, err := common.FindRepoRoot()
+	if err != nil {
+		log.Fatal(err)
+	}
+	failed := false
+	for _, rev := range args {
+		sha, err := common.ResolveCommit(repoPath, rev)
+		if err != nil {
+			log.Fatal(err)
+		}
+		check, err := checkCommitSignature(repoPath, sha)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintln(os.Stderr, check)
+		failed = failed || !check.good()
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func VerifyTagCommand(cmd *cobra.Command, args []string) {
+	repoPath, err := common.FindRepoRoot()
+	if err != nil {
+		log.Fatal(err)
+	}
+	failed := false
+	for _, name := range args {
+		sha, err := common.ReadRef(repoPath, common.RefsDir+"/"+common.TagsDir+"/"+name)
+		if err != nil {
+			log.Fatalf("tag '%s' not found", name)
+		}
+		objType, data, err := common.ReadObjectWithType(repoPath, sha)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if objType != common.TagFile {
+			log.Fatalf("%s: cannot verify a non-tag object of type %s", name, objType)
+		}
+		tag, err := common.ParseTag(data)
+		if err != nil {
+			log.Fatal(err)
+		}
+		check := checkSignature(repoPath, tag.Signature, common.SignedTagPayload(data), tag.TaggerName())
+		fmt.Fprintln(os.Stderr, check)
+		failed = failed || !check.good()
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
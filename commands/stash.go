@@ -0,0 +1,295 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hanzala211/mini-git/common"
+	"github.com/spf13/cobra"
+)
+
+const stashRef = "refs/stash"
+
+// a stash entry is a commit of the working tree whose first parent is HEAD at
+// the time of the stash and whose second parent is a commit of the index
+
+func firstLine(message string) string {
+	return strings.Split(message, "\n")[0]
+}
+
+func stashPush(repoRoot string, message string) {
+	headSha, err := common.GetParentSha(repoRoot)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if headSha == "" {
+		log.Fatal("you do not have the initial commit yet")
+	}
+	headCommit, err := common.ReadCommit(repoRoot, headSha)
+	if err != nil {
+		log.Fatal(err)
+	}
+	head, err := treeIndex(repoRoot, headCommit.Tree)
+	if err != nil {
+		log.Fatal(err)
+	}
+	index, err := common.ReadIndex(repoRoot)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	lfs, err := loadLFSTracker(repoRoot)
+	if err != nil {
+		log.Fatal(err)
+	}
+	worktree := make(common.Index)
+	for path := range index {
+		result := hashFile(repoRoot, lfs, filepath.Join(repoRoot, filepath.FromSlash(path)))
+		if errors.Is(result.err, fs.ErrNotExist) {
+			continue // deleted in the working tree
+		}
+		if result.err != nil {
+			log.Fatal(result.err)
+		}
+		worktree[path] = result.sha
+	}
+	if maps.Equal(worktree, index) && maps.Equal(index, head) {
+		fmt.Println("No local changes to save")
+		return
+	}
+
+	branch := currentBranchName(repoRoot)
+	subject := fmt.Sprintf("%s %s", headSha[:7], firstLine(headCommit.Message))
+	if message == "" {
+		message = fmt.Sprintf("WIP on %s: %s", branch, subject)
+	} else {
+		message = fmt.Sprintf("On %s: %s", branch, message)
+	}
+	indexTree, err := buildTree(repoRoot, index)
+	if err != nil {
+		log.Fatalf("failed to build trees: %v", err)
+	}
+	worktreeTree, err := buildTree(repoRoot, worktree)
+	if err != nil {
+		log.Fatalf("failed to build trees: %v", err)
+	}
+	indexCommit, err := common.WriteCommit(repoRoot, &common.Commit{
+		Tree:    indexTree,
+		Parents: []string{headSha},
+		Message: fmt.Sprintf("index on %s: %s", branch, subject),
+	})
+	if err != nil {
+		log.Fatalf("failed to write commit object: %v", err)
+	}
+	stashCommit, err := common.WriteCommit(repoRoot, &common.Commit{
+		Tree:    worktreeTree,
+		Parents: []string{headSha, indexCommit},
+		Message: message,
+	})
+	if err != nil {
+		log.Fatalf("failed to write commit object: %v", err)
+	}
+	if err := common.UpdateRef(repoRoot, stashRef, stashCommit, message); err != nil {
+		log.Fatalf("failed to update %s: %v", stashRef, err)
+	}
+
+	// go back to a clean HEAD
+	if err := updateWorktree(repoRoot, worktree, head); err != nil {
+		log.Fatal(err)
+	}
+	if err := common.WriteIndex(repoRoot, head); err != nil {
+		log.Fatalf("failed to write index: %v", err)
+	}
+	fmt.Printf("Saved working directory and index state %s\n", message)
+}
+
+// parseStashArg accepts "stash@{n}", "n" or nothing (meaning stash@{0})
+func parseStashArg(args []string) int {
+	if len(args) == 0 {
+		return 0
+	}
+	arg := strings.TrimSuffix(strings.TrimPrefix(args[0], "stash@{"), "}")
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 0 {
+		log.Fatalf("%s is not a valid stash reference", args[0])
+	}
+	return n
+}
+
+func readStashEntries(repoRoot string) []common.ReflogEntry {
+	entries, err := common.ReadReflog(repoRoot, stashRef)
+	if err != nil {
+		log.Fatalf("failed to read stash list: %v", err)
+	}
+	return entries
+}
+
+func stashEntry(repoRoot string, n int) *common.Commit {
+	entries := readStashEntries(repoRoot)
+	if n >= len(entries) {
+		if len(entries) == 0 {
+			log.Fatal("no stash entries found")
+		}
+		log.Fatalf("stash@{%d} does not exist, there are only %d entries", n, len(entries))
+	}
+	stash, err := common.ReadCommit(repoRoot, entries[n].NewSha)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(stash.Parents) != 2 {
+		log.Fatalf("stash@{%d} is not a valid stash commit", n)
+	}
+	return stash
+}
+
+// stashApply merges a stash into the working tree and index, returning false on conflicts
+func stashApply(repoRoot string, n int) bool {
+	stash := stashEntry(repoRoot, n)
+	base, err := commitIndex(repoRoot, stash.Parents[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	staged, err := commitIndex(repoRoot, stash.Parents[1])
+	if err != nil {
+		log.Fatal(err)
+	}
+	worktree, err := treeIndex(repoRoot, stash.Tree)
+	if err != nil {
+		log.Fatal(err)
+	}
+	current, err := common.ReadIndex(repoRoot)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	changed, err := localChanges(repoRoot)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, path := range changed {
+		if worktree[path] != base[path] || staged[path] != base[path] {
+			log.Fatalf("your local changes to %s would be overwritten by stash apply, commit or stash them first", path)
+		}
+	}
+
+	worktreeResult, err := mergeIndexes(repoRoot, base, current, worktree, "Updated upstream", "Stashed changes")
+	if err != nil {
+		log.Fatalf("failed to merge stash: %v", err)
+	}
+	if err := applyMergeResult(repoRoot, current, worktreeResult); err != nil {
+		log.Fatal(err)
+	}
+
+	// the working tree now has everything, put back only what was staged
+	newIndex := current
+	indexResult, err := mergeIndexes(repoRoot, base, current, staged, "Updated upstream", "Stashed changes")
+	if err != nil {
+		log.Fatalf("failed to merge stash: %v", err)
+	}
+	if len(indexResult.Conflicts) == 0 {
+		newIndex = indexResult.Index
+	} else {
+		fmt.Println("warning: could not restore the staged changes, they are left unstaged")
+	}
+	for _, path := range worktreeResult.Conflicts {
+		if sha, ok := current[path]; ok {
+			newIndex[path] = sha
+		} else {
+			delete(newIndex, path)
+		}
+	}
+	if err := common.WriteIndex(repoRoot, newIndex); err != nil {
+		log.Fatalf("failed to write index: %v", err)
+	}
+
+	for _, path := range worktreeResult.Conflicts {
+		fmt.Printf("CONFLICT (content): Merge conflict in %s\n", path)
+	}
+	return len(worktreeResult.Conflicts) == 0
+}
+
+func stashDrop(repoRoot string, n int) {
+	entries := readStashEntries(repoRoot)
+	if n >= len(entries) {
+		log.Fatalf("stash@{%d} does not exist", n)
+	}
+	dropped := entries[n]
+	entries = append(entries[:n], entries[n+1:]...)
+	if len(entries) == 0 {
+		if err := common.DeleteRef(repoRoot, stashRef); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		if err := common.WriteReflog(repoRoot, stashRef, entries); err != nil {
+			log.Fatalf("failed to write stash list: %v", err)
+		}
+		if err := common.WriteRef(repoRoot, stashRef, entries[0].NewSha); err != nil {
+			log.Fatal(err)
+		}
+	}
+	fmt.Printf("Dropped stash@{%d} (%s)\n", n, dropped.NewSha)
+}
+
+func stashShow(repoRoot string, n int, patch bool) {
+	stash := stashEntry(repoRoot, n)
+	base, err := commitIndex(repoRoot, stash.Parents[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	worktree, err := treeIndex(repoRoot, stash.Tree)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := printIndexDiff(repoRoot, base, worktree, patch); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func StashCommand(cmd *cobra.Command, args []string) {
+	repoPath, err := common.FindRepoRoot()
+	if err != nil {
+		log.Fatal(err)
+	}
+	action := "push"
+	if len(args) > 0 {
+		action, args = args[0], args[1:]
+	}
+
+	switch action {
+	case "push", "save":
+		message, _ := cmd.Flags().GetString("message")
+		if message == "" && len(args) > 0 {
+			message = strings.Join(args, " ")
+		}
+		stashPush(repoPath, message)
+	case "list":
+		for i, entry := range readStashEntries(repoPath) {
+			fmt.Printf("stash@{%d}: %s\n", i, entry.Reason)
+		}
+	case "show":
+		patch, _ := cmd.Flags().GetBool("patch")
+		stashShow(repoPath, parseStashArg(args), patch)
+	case "apply":
+		if !stashApply(repoPath, parseStashArg(args)) {
+			os.Exit(1)
+		}
+	case "pop":
+		n := parseStashArg(args)
+		if !stashApply(repoPath, n) {
+			fmt.Println("The stash entry is kept in case you need it again.")
+			os.Exit(1)
+		}
+		stashDrop(repoPath, n)
+	case "drop":
+		stashDrop(repoPath, parseStashArg(args))
+	default:
+		log.Fatalf("unknown stash subcommand %q", action)
+	}
+}
@@ -0,0 +1,105 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/hanzala211/mini-git/common"
+	"github.com/spf13/cobra"
+)
+
+func tagRefName(name string) string {
+	return common.RefsDir + "/" + common.TagsDir + "/" + name
+}
+
+func listTags(repoRoot string) {
+	refs, err := common.ListRefs(repoRoot)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var names []string
+	for name := range refs {
+		if tag, ok := strings.CutPrefix(name, tagRefName("")); ok {
+			names = append(names, tag)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Println(name)
+	}
+}
+
+func deleteTags(repoRoot string, names []string) {
+	for _, name := range names {
+		sha, err := common.ReadRef(repoRoot, tagRefName(name))
+		if err != nil {
+			log.Fatalf("tag '%s' not found", name)
+		}
+		if err := common.DeleteRef(repoRoot, tagRefName(name)); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Deleted tag '%s' (was %s)\n", name, sha[:7])
+	}
+}
+
+func TagCommand(cmd *cobra.Command, args []string) {
+	repoPath, err := common.FindRepoRoot()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if deleteFlag, _ := cmd.Flags().GetBool("delete"); deleteFlag {
+		deleteTags(repoPath, args)
+		return
+	}
+	if len(args) == 0 {
+		listTags(repoPath)
+		return
+	}
+	name := args[0]
+	// ~ and ^ would be read as revision suffixes
+	if strings.ContainsAny(name, " ~^:") || strings.Contains(name, "..") || strings.HasPrefix(name, "-") {
+		log.Fatalf("'%s' is not a valid tag name", name)
+	}
+	rev := common.HEAD
+	if len(args) > 1 {
+		rev = args[1]
+	}
+	force, _ := cmd.Flags().GetBool("force")
+	if _, err := common.ReadRef(repoPath, tagRefName(name)); err == nil && !force {
+		log.Fatalf("tag '%s' already exists", name)
+	}
+	target, err := common.ResolveRevision(repoPath, rev)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	message, _ := cmd.Flags().GetString("message")
+	annotate, _ := cmd.Flags().GetBool("annotate")
+	sign, err := wantSignature(repoPath, cmd, "sign")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if annotate || message != "" || cmd.Flags().Changed("sign") {
+		if message == "" {
+			log.Fatal("an annotated tag needs a message, pass it with -m")
+		}
+		objType, _, err := common.ReadObjectWithType(repoPath, target)
+		if err != nil {
+			log.Fatal(err)
+		}
+		tag := &common.Tag{Object: target, Type: objType, Name: name, Message: message}
+		if sign {
+			if err := signTag(repoPath, tag); err != nil {
+				log.Fatalf("failed to sign the tag: %v", err)
+			}
+		}
+		if target, err = common.WriteTag(repoPath, tag); err != nil {
+			log.Fatalf("failed to write tag object: %v", err)
+		}
+	}
+	if err := common.WriteRef(repoPath, tagRefName(name), target); err != nil {
+		log.Fatal(err)
+	}
+}
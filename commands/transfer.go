@@ -0,0 +1,232 @@
+package commands
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+
+	"github.com/hanzala211/mini-git/common"
+)
+
+// objectsToSend lists the objects reachable from wants that the receiving side
+// does not have. The walk stops at objects it has: a commit it has comes with
+// its whole history already. Every object is listed after the objects it
+// links to, so a transfer that stops halfway never leaves a commit behind
+// whose history is missing, and the has check above stays true.
+func objectsToSend(repoRoot string, wants []string, has func(sha string) bool) ([]string, error) {
+	type pending struct {
+		sha      string
+		expanded bool // its links are on the stack above it
+	}
+	var missing []string
+	seen := make(map[string]bool)
+	var stack []pending
+	for i := len(wants) - 1; i >= 0; i-- {
+		stack = append(stack, pending{sha: wants[i]})
+	}
+	for len(stack) > 0 {
+		top := len(stack) - 1
+		sha := stack[top].sha
+		if stack[top].expanded {
+			stack = stack[:top]
+			missing = append(missing, sha)
+			continue
+		}
+		if sha == "" || seen[sha] || has(sha) {
+			stack = stack[:top]
+			continue
+		}
+		seen[sha] = true
+		objType, content, err := common.ReadObjectWithType(repoRoot, sha)
+		if err != nil {
+			return nil, err
+		}
+		links, err := objectLinks(objType, content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s %s: %w", objType, sha, err)
+		}
+		stack[top].expanded = true
+		for i := len(links) - 1; i >= 0; i-- {
+			stack = append(stack, pending{sha: links[i].sha})
+		}
+	}
+	return missing, nil
+}
+
+// checkConnected makes sure repoRoot has every object reachable from the
+// commit sha, like git's receive-pack does before it moves a ref. The walk
+// stops at the shas in complete, whose history is known to be there. Blobs
+// are only opened to check their type, never read.
+func checkConnected(repoRoot string, sha string, complete map[string]bool) error {
+	seen := make(map[string]bool)
+	queue := []fsckLink{{sha, common.CommitFile}}
+	for len(queue) > 0 {
+		link := queue[0]
+		queue = queue[1:]
+		if seen[link.sha] || complete[link.sha] {
+			continue
+		}
+		seen[link.sha] = true
+		if !common.ObjectExists(repoRoot, link.sha) {
+			return fmt.Errorf("missing %s %s", link.objType, link.sha)
+		}
+		if link.objType == common.BlobFile {
+			blob, err := common.OpenObject(repoRoot, link.sha)
+			if err != nil {
+				return err
+			}
+			blob.Close()
+			if blob.Type != common.BlobFile {
+				return fmt.Errorf("object %s is a %s, not a blob", link.sha, blob.Type)
+			}
+			continue
+		}
+		objType, content, err := common.ReadObjectWithType(repoRoot, link.sha)
+		if err != nil {
+			return err
+		}
+		if objType != link.objType {
+			return fmt.Errorf("object %s is a %s, not a %s", link.sha, objType, link.objType)
+		}
+		links, err := objectLinks(objType, content)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s %s: %w", objType, link.sha, err)
+		}
+		queue = append(queue, links...)
+	}
+	return nil
+}
+
+// copyObjects copies objects between two repositories on disk, every object is
+// hashed again on the way in so a corrupt source cannot slip one through
+func copyObjects(srcRoot string, dstRoot string, shas []string) error {
+	for _, sha := range shas {
+		objType, content, err := common.ReadObjectWithType(srcRoot, sha)
+		if err != nil {
+			return err
+		}
+		written, err := common.WriteObject(dstRoot, content, objType, "")
+		if err != nil {
+			return err
+		}
+		if written != sha {
+			return fmt.Errorf("object %s is corrupt, its content hashes to %s", sha, written)
+		}
+	}
+	return nil
+}
+
+// reachableObjects is every object reachable from the commits in shas that
+// repoRoot has, it is what the other side of a transfer can be assumed to have
+func reachableObjects(repoRoot string, shas []string) (map[string]bool, error) {
+	var present []string
+	for _, sha := range shas {
+		if common.ObjectExists(repoRoot, sha) {
+			present = append(present, sha)
+		}
+	}
+	objects, err := objectsToSend(repoRoot, present, func(string) bool { return false })
+	if err != nil {
+		return nil, err
+	}
+	reachable := make(map[string]bool, len(objects))
+	for _, sha := range objects {
+		reachable[sha] = true
+	}
+	return reachable, nil
+}
+
+// writeObjectStream writes objects the way they travel between repositories:
+// a "<sha> <type> <size>\n" header followed by size bytes of content for each
+func writeObjectStream(w io.Writer, repoRoot string, shas []string) error {
+	for _, sha := range shas {
+		objType, content, err := common.ReadObjectWithType(repoRoot, sha)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "%s %s %d\n", sha, objType, len(content)); err != nil {
+			return err
+		}
+		if _, err := w.Write(content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// maxStreamObjectSize bounds the size a peer can claim for one object, the
+// content is streamed to disk so this only guards against absurd headers
+const maxStreamObjectSize = 16 << 30
+
+// streamHasher hashes the content of a streamed object and counts its bytes
+type streamHasher struct {
+	hash.Hash
+	read int64
+}
+
+func (h *streamHasher) Write(p []byte) (int, error) {
+	h.read += int64(len(p))
+	return h.Hash.Write(p)
+}
+
+// scanObjectStream reads an object stream and calls fn with the content of
+// each object as a reader of size bytes. Whatever fn does not read is skipped,
+// and the content is checked against the sha it was sent as once fn returns.
+func scanObjectStream(r io.Reader, fn func(sha string, objType string, size int64, content io.Reader) error) error {
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadSlice('\n') // bounded by the buffer, which a valid header never fills
+		if err == io.EOF && len(line) == 0 {
+			return nil
+		}
+		if err == bufio.ErrBufferFull {
+			return fmt.Errorf("invalid object header: too long")
+		}
+		if err != nil {
+			return fmt.Errorf("truncated object stream: %w", err)
+		}
+		header := string(line)
+		var sha, objType string
+		var size int64
+		if _, err := fmt.Sscanf(header, "%s %s %d\n", &sha, &objType, &size); err != nil {
+			return fmt.Errorf("invalid object header %q", strings.TrimSpace(header))
+		}
+		if objType != common.BlobFile && objType != common.TreeFile && objType != common.CommitFile && objType != common.TagFile {
+			return fmt.Errorf("unknown object type %q for %s", objType, sha)
+		}
+		if size < 0 || size > maxStreamObjectSize {
+			return fmt.Errorf("invalid size %d for object %s", size, sha)
+		}
+		hasher := &streamHasher{Hash: sha1.New()}
+		fmt.Fprintf(hasher.Hash, "%s %d\x00", objType, size)
+		content := io.TeeReader(io.LimitReader(reader, size), hasher)
+		if err := fn(sha, objType, size, content); err != nil {
+			return err
+		}
+		if _, err := io.Copy(io.Discard, content); err != nil {
+			return fmt.Errorf("truncated object %s: %w", sha, err)
+		}
+		if hasher.read != size {
+			return fmt.Errorf("truncated object %s: %w", sha, io.ErrUnexpectedEOF)
+		}
+		if hashed := fmt.Sprintf("%x", hasher.Sum(nil)); hashed != sha {
+			return fmt.Errorf("object %s is corrupt, its content hashes to %s", sha, hashed)
+		}
+	}
+}
+
+// readObjectStream stores every object of a stream in repoRoot and returns how many it read
+func readObjectStream(r io.Reader, repoRoot string) (int, error) {
+	count := 0
+	err := scanObjectStream(r, func(sha string, objType string, size int64, content io.Reader) error {
+		if _, _, err := common.WriteObjectFromReader(repoRoot, content, size, objType); err != nil {
+			return fmt.Errorf("failed to store object %s: %w", sha, err)
+		}
+		count++
+		return nil
+	})
+	return count, err
+}
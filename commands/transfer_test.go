@@ -0,0 +1,62 @@
+package commands
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/hanzala211/mini-git/common"
+)
+
+func TestObjectsToSendListsLinksFirst(t *testing.T) {
+	t.Setenv("MINIGIT_AUTHOR_NAME", "Test")
+	t.Setenv("MINIGIT_AUTHOR_EMAIL", "test@example.com")
+	repo := newTestRepo(t, filepath.Join(t.TempDir(), "repo"))
+	first := testCommit(t, repo, "master", "", map[string]string{"a": "shared\n", "dir/b": "shared\n"})
+	second := testCommit(t, repo, "master", first, map[string]string{"dir/c": "shared\n", "d": "new\n"})
+	tip := testCommit(t, repo, "master", second, map[string]string{"a": "changed\n"})
+
+	shas, err := objectsToSend(repo, []string{tip}, func(string) bool { return false })
+	if err != nil {
+		t.Fatal(err)
+	}
+	position := make(map[string]int, len(shas))
+	for i, sha := range shas {
+		if _, dup := position[sha]; dup {
+			t.Fatalf("%s is listed twice", sha)
+		}
+		position[sha] = i
+	}
+	for i, sha := range shas {
+		objType, content, err := common.ReadObjectWithType(repo, sha)
+		if err != nil {
+			t.Fatal(err)
+		}
+		links, err := objectLinks(objType, content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, link := range links {
+			at, ok := position[link.sha]
+			if !ok {
+				t.Fatalf("%s links to %s, which is not listed", sha, link.sha)
+			}
+			if at > i {
+				t.Fatalf("%s %s is listed before %s, which it links to", objType, sha, link.sha)
+			}
+		}
+	}
+	if shas[len(shas)-1] != tip {
+		t.Fatalf("the last object is %s, want the tip %s", shas[len(shas)-1], tip)
+	}
+
+	// a commit the other side has stands for its whole history
+	shas, err = objectsToSend(repo, []string{tip}, func(sha string) bool { return sha == second })
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sha := range shas {
+		if sha == first || sha == second {
+			t.Fatalf("%s was sent although the other side has it", sha)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hanzala211/mini-git/common"
+)
+
+// refAdvertisement is what a remote tells about itself before a fetch or push
+type refAdvertisement struct {
+	Head string            `json:"head"` // the branch HEAD is on, "" when detached
+	Refs map[string]string `json:"refs"` // refs/heads/* plus HEAD itself
+}
+
+// refUpdate asks the remote to move Name from Old to New, Old is "" for a new ref
+type refUpdate struct {
+	Name string `json:"name"`
+	Old  string `json:"old"`
+	New  string `json:"new"`
+}
+
+// Transport is how fetch, push and clone talk to another repository, every
+// protocol implements it and openTransport picks one from the remote url
+type Transport interface {
+	// ListRefs returns the remote's branches and HEAD
+	ListRefs() (*refAdvertisement, error)
+	// Fetch copies into repoRoot the objects reachable from wants, haves are
+	// commits repoRoot already has so their history can be left out
+	Fetch(repoRoot string, wants []string, haves []string) (int, error)
+	// Push sends the objects the updates need from repoRoot and then applies
+	// the updates, each one only if the remote ref still has its Old value
+	Push(repoRoot string, updates []refUpdate) (int, error)
+	Close() error
+}
+
+// normalizeRemoteURL makes local paths absolute so a remote works from any directory
+func normalizeRemoteURL(url string) (string, error) {
+	if strings.Contains(url, "://") {
+		return url, nil
+	}
+	return filepath.Abs(strings.TrimSuffix(filepath.Clean(url), string(filepath.Separator)+common.RootDir))
+}
+
+func openTransport(url string) (Transport, error) {
+	if strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://") {
+		return newHTTPTransport(url), nil
+	}
+	if strings.Contains(url, "://") {
+		return nil, fmt.Errorf("unsupported remote url %s", url)
+	}
+	root, err := normalizeRemoteURL(url)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(root); err == nil && info.Mode().IsRegular() {
+		return &bundleTransport{path: root}, nil
+	}
+	if info, err := os.Stat(filepath.Join(root, common.RootDir)); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("%s does not appear to be a mini-git repository", url)
+	}
+	return &localTransport{root: root}, nil
+}
+
+// advertiseRefs is the serving side of ListRefs
+func advertiseRefs(repoRoot string) (*refAdvertisement, error) {
+	refs, err := common.ListRefs(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	ads := &refAdvertisement{Refs: make(map[string]string)}
+	for name, sha := range refs {
+		if strings.HasPrefix(name, "refs/heads/") {
+			ads.Refs[name] = sha
+		}
+	}
+	headRef, err := common.GetHeadRef(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	headSha, err := common.GetParentSha(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	if headSha != "" {
+		ads.Refs[common.HEAD] = headSha
+	}
+	if headRef != common.HEAD {
+		ads.Head = headRef
+	}
+	return ads, nil
+}
+
+// checkUpdates rejects updates the serving side never takes, it runs before
+// any object is received so a refused push does not fill the store first
+func checkUpdates(repoRoot string, updates []refUpdate) error {
+	headRef, err := common.GetHeadRef(repoRoot)
+	if err != nil {
+		return err
+	}
+	for _, update := range updates {
+		if !strings.HasPrefix(update.Name, "refs/heads/") {
+			return fmt.Errorf("refusing to update %s, only branches can be pushed", update.Name)
+		}
+		if update.Name == headRef {
+			return fmt.Errorf("refusing to update checked out branch %s", update.Name)
+		}
+		// a linked worktree of the remote has its own HEAD, its branch is just as checked out
+		if path, err := worktreeWithBranch(repoRoot, update.Name); err != nil {
+			return err
+		} else if path != "" {
+			return fmt.Errorf("refusing to update checked out branch %s, it is checked out at '%s'", update.Name, path)
+		}
+	}
+	return nil
+}
+
+// receiveUpdates is the serving side of Push once the objects are in: the
+// history of every update has to be complete, and the updates go through one
+// transaction, so they all land or none do if any ref is no longer where the
+// pusher saw it
+func receiveUpdates(repoRoot string, updates []refUpdate, pusher string) error {
+	if err := checkUpdates(repoRoot, updates); err != nil {
+		return err
+	}
+	refs, err := common.ListRefs(repoRoot)
+	if err != nil {
+		return err
+	}
+	// what the refs point to is complete already, the walk stops there
+	complete := make(map[string]bool, len(refs))
+	for _, sha := range refs {
+		complete[sha] = true
+	}
+	transaction := common.NewRefTransaction(repoRoot)
+	for _, update := range updates {
+		if err := checkConnected(repoRoot, update.New, complete); err != nil {
+			return fmt.Errorf("refusing to update %s: %w", update.Name, err)
+		}
+		transaction.Update(update.Name, update.New, update.Old, "push from "+pusher)
+	}
+	if err := transaction.Commit(); err != nil {
+		var conflict *common.RefConflictError
+		if errors.As(err, &conflict) {
+			return fmt.Errorf("%s has moved on the remote, fetch first", conflict.Name)
+		}
+		return err
+	}
+	return nil
+}
+
+// localTransport talks to a repository on the same filesystem
+type localTransport struct {
+	root string
+}
+
+func (t *localTransport) ListRefs() (*refAdvertisement, error) {
+	return advertiseRefs(t.root)
+}
+
+func (t *localTransport) Fetch(repoRoot string, wants []string, haves []string) (int, error) {
+	// both object stores are right here, so the local store answers what it has
+	shas, err := objectsToSend(t.root, wants, func(sha string) bool { return common.ObjectExists(repoRoot, sha) })
+	if err != nil {
+		return 0, err
+	}
+	return len(shas), copyObjects(t.root, repoRoot, shas)
+}
+
+func (t *localTransport) Push(repoRoot string, updates []refUpdate) (int, error) {
+	var wants []string
+	for _, update := range updates {
+		wants = append(wants, update.New)
+	}
+	if err := checkUpdates(t.root, updates); err != nil {
+		return 0, err
+	}
+	shas, err := objectsToSend(repoRoot, wants, func(sha string) bool { return common.ObjectExists(t.root, sha) })
+	if err != nil {
+		return 0, err
+	}
+	if err := copyObjects(repoRoot, t.root, shas); err != nil {
+		return 0, err
+	}
+	return len(shas), receiveUpdates(t.root, updates, repoRoot)
+}
+
+func (t *localTransport) Close() error {
+	return nil
+}
+
+// bundleTransport reads from a bundle file, it can only be fetched from
+type bundleTransport struct {
+	path string
+}
+
+func (t *bundleTransport) ListRefs() (*refAdvertisement, error) {
+	file, _, header, err := openBundle(t.path)
+	if err != nil {
+		return nil, err
+	}
+	file.Close()
+	ads := &refAdvertisement{Refs: make(map[string]string)}
+	var branches []string
+	for name, sha := range header.Refs {
+		if strings.HasPrefix(name, "refs/heads/") {
+			ads.Refs[name] = sha
+			branches = append(branches, name)
+		}
+	}
+	sort.Strings(branches)
+	// a bundle does not say which branch HEAD is on, so guess from its commit
+	headSha, ok := header.Refs[common.HEAD]
+	if !ok && len(branches) > 0 {
+		headSha = ads.Refs[branches[0]]
+		if sha, found := ads.Refs["refs/heads/master"]; found {
+			headSha = sha
+		}
+	}
+	if headSha != "" {
+		ads.Refs[common.HEAD] = headSha
+		for _, name := range branches {
+			if ads.Refs[name] == headSha && (ads.Head == "" || name == "refs/heads/master") {
+				ads.Head = name
+			}
+		}
+	}
+	return ads, nil
+}
+
+func (t *bundleTransport) Fetch(repoRoot string, wants []string, haves []string) (int, error) {
+	file, reader, header, err := openBundle(t.path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	if missing := missingPrerequisites(repoRoot, header); len(missing) > 0 {
+		return 0, fmt.Errorf("repository lacks these prerequisite commits:\n%s", strings.Join(missing, "\n"))
+	}
+	// a bundle is one stream of objects, all of it is read whatever is wanted
+	return readObjectStream(reader, repoRoot)
+}
+
+func (t *bundleTransport) Push(repoRoot string, updates []refUpdate) (int, error) {
+	return 0, fmt.Errorf("cannot push to bundle %s", t.path)
+}
+
+func (t *bundleTransport) Close() error {
+	return nil
+}
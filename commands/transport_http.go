@@ -0,0 +1,119 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// httpTransport talks to a repository served by mini-git serve
+type httpTransport struct {
+	url    string
+	client *http.Client
+	ads    *refAdvertisement // from the last ListRefs, push needs it to leave out what the remote has
+}
+
+func newHTTPTransport(url string) *httpTransport {
+	return &httpTransport{url: strings.TrimSuffix(url, "/"), client: http.DefaultClient}
+}
+
+// responseError turns a failed response into an error carrying the server's message
+func responseError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	message := strings.TrimSpace(string(body))
+	if message == "" {
+		message = resp.Status
+	}
+	return fmt.Errorf("remote: %s", message)
+}
+
+func (t *httpTransport) ListRefs() (*refAdvertisement, error) {
+	resp, err := t.client.Get(t.url + refsEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp)
+	}
+	var ads refAdvertisement
+	if err := json.NewDecoder(resp.Body).Decode(&ads); err != nil {
+		return nil, fmt.Errorf("invalid ref advertisement from %s: %w", t.url, err)
+	}
+	if ads.Refs == nil {
+		ads.Refs = make(map[string]string)
+	}
+	t.ads = &ads
+	return &ads, nil
+}
+
+func (t *httpTransport) Fetch(repoRoot string, wants []string, haves []string) (int, error) {
+	body, err := json.Marshal(fetchRequest{Wants: wants, Haves: haves})
+	if err != nil {
+		return 0, err
+	}
+	resp, err := t.client.Post(t.url+fetchEndpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, responseError(resp)
+	}
+	return readObjectStream(resp.Body, repoRoot)
+}
+
+func (t *httpTransport) Push(repoRoot string, updates []refUpdate) (int, error) {
+	if t.ads == nil {
+		if _, err := t.ListRefs(); err != nil {
+			return 0, err
+		}
+	}
+	// whatever the remote's refs reach locally it has too, so it is left out
+	var remoteShas []string
+	for _, sha := range t.ads.Refs {
+		remoteShas = append(remoteShas, sha)
+	}
+	remote, err := reachableObjects(repoRoot, remoteShas)
+	if err != nil {
+		return 0, err
+	}
+	var wants []string
+	for _, update := range updates {
+		wants = append(wants, update.New)
+	}
+	shas, err := objectsToSend(repoRoot, wants, func(sha string) bool { return remote[sha] })
+	if err != nil {
+		return 0, err
+	}
+
+	header, err := json.Marshal(updates)
+	if err != nil {
+		return 0, err
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := pw.Write(append(header, '\n'))
+		if err == nil {
+			err = writeObjectStream(pw, repoRoot, shas)
+		}
+		pw.CloseWithError(err)
+	}()
+	resp, err := t.client.Post(t.url+pushEndpoint, "application/octet-stream", pr)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, responseError(resp)
+	}
+	return len(shas), nil
+}
+
+func (t *httpTransport) Close() error {
+	t.client.CloseIdleConnections()
+	return nil
+}
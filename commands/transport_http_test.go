@@ -0,0 +1,225 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hanzala211/mini-git/common"
+)
+
+// newTestRepo creates an empty repository at dir
+func newTestRepo(t *testing.T, dir string) string {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := initRepository(dir); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// testCommit commits files on top of parent and points branch at the result
+func testCommit(t *testing.T, repoRoot string, branch string, parent string, files map[string]string) string {
+	t.Helper()
+	index, err := commitIndex(repoRoot, parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, content := range files {
+		sha, err := common.WriteObject(repoRoot, []byte(content), common.BlobFile, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		index[path] = sha
+	}
+	tree, err := buildTree(repoRoot, index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit := &common.Commit{Tree: tree, Message: "test commit"}
+	if parent != "" {
+		commit.Parents = []string{parent}
+	}
+	sha, err := common.WriteCommit(repoRoot, commit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := common.UpdateRef(repoRoot, "refs/heads/"+branch, sha, "commit: test commit"); err != nil {
+		t.Fatal(err)
+	}
+	return sha
+}
+
+func readTestRef(t *testing.T, repoRoot string, refName string) string {
+	t.Helper()
+	sha, err := common.ReadRef(repoRoot, refName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sha
+}
+
+func TestHTTPCloneFetchPush(t *testing.T) {
+	t.Setenv("MINIGIT_AUTHOR_NAME", "Test")
+	t.Setenv("MINIGIT_AUTHOR_EMAIL", "test@example.com")
+	base := t.TempDir()
+	origin := newTestRepo(t, filepath.Join(base, "origin"))
+	first := testCommit(t, origin, "master", "", map[string]string{"README": "hello\n", "src/main.go": "package main\n"})
+
+	server := httptest.NewServer(newRepoServer(base))
+	defer server.Close()
+	transport, err := openTransport(server.URL + "/origin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transport.Close()
+
+	// clone
+	clone := newTestRepo(t, filepath.Join(t.TempDir(), "clone"))
+	ads, _, err := fetchRemote(clone, defaultRemote, transport, false)
+	if err != nil {
+		t.Fatalf("clone fetch: %v", err)
+	}
+	if ads.Head != "refs/heads/master" || ads.Refs[common.HEAD] != first {
+		t.Fatalf("advertised HEAD %q at %s, want refs/heads/master at %s", ads.Head, ads.Refs[common.HEAD], first)
+	}
+	if err := cloneCheckout(clone, ads.Head, first, "clone: test"); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(filepath.Join(clone, "src", "main.go"))
+	if err != nil || string(content) != "package main\n" {
+		t.Fatalf("checked out src/main.go = %q, %v", content, err)
+	}
+
+	// fetch only brings the new commit's objects
+	second := testCommit(t, origin, "master", first, map[string]string{"README": "hello again\n"})
+	_, updated, err := fetchRemote(clone, defaultRemote, transport, false)
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if len(updated) != 1 || updated[0].Old != first || updated[0].New != second {
+		t.Fatalf("fetch updated %+v, want master %s..%s", updated, first, second)
+	}
+	if got := readTestRef(t, clone, "refs/remotes/origin/master"); got != second {
+		t.Fatalf("origin/master = %s, want %s", got, second)
+	}
+	if _, err := commitIndex(clone, second); err != nil {
+		t.Fatalf("fetched commit is incomplete: %v", err)
+	}
+
+	// push a new branch, then refuse a stale and a checked out one
+	topic := testCommit(t, clone, "topic", second, map[string]string{"NEWS": "pushed\n"})
+	if _, err := transport.Push(clone, []refUpdate{{Name: "refs/heads/topic", New: topic}}); err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	if got := readTestRef(t, origin, "refs/heads/topic"); got != topic {
+		t.Fatalf("origin topic = %s, want %s", got, topic)
+	}
+	if _, err := commitIndex(origin, topic); err != nil {
+		t.Fatalf("pushed commit is incomplete: %v", err)
+	}
+	if _, err := transport.Push(clone, []refUpdate{{Name: "refs/heads/topic", Old: second, New: second}}); err == nil || !strings.Contains(err.Error(), "fetch first") {
+		t.Fatalf("stale push error = %v, want fetch first", err)
+	}
+	if _, err := transport.Push(clone, []refUpdate{{Name: "refs/heads/master", Old: second, New: topic}}); err == nil || !strings.Contains(err.Error(), "checked out") {
+		t.Fatalf("push to checked out branch error = %v, want a refusal", err)
+	}
+}
+
+func TestHTTPFetchRefusesUnadvertisedObjects(t *testing.T) {
+	t.Setenv("MINIGIT_AUTHOR_NAME", "Test")
+	t.Setenv("MINIGIT_AUTHOR_EMAIL", "test@example.com")
+	base := t.TempDir()
+	origin := newTestRepo(t, filepath.Join(base, "origin"))
+	testCommit(t, origin, "master", "", map[string]string{"README": "hello\n"})
+	secret, err := common.WriteObject(origin, []byte("not on any ref\n"), common.BlobFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(newRepoServer(base))
+	defer server.Close()
+	transport, err := openTransport(server.URL + "/origin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transport.Close()
+	clone := newTestRepo(t, filepath.Join(t.TempDir(), "clone"))
+	if _, err := transport.Fetch(clone, []string{secret}, nil); err == nil || !strings.Contains(err.Error(), "not our ref") {
+		t.Fatalf("fetch of an unadvertised object error = %v, want not our ref", err)
+	}
+	if common.ObjectExists(clone, secret) {
+		t.Fatal("unadvertised object was handed out")
+	}
+}
+
+// rawPush posts a push with updates and only the objects in shas, the way a
+// broken or hostile client could
+func rawPush(t *testing.T, url string, from string, updates []refUpdate, shas []string) *http.Response {
+	t.Helper()
+	header, err := json.Marshal(updates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body bytes.Buffer
+	body.Write(append(header, '\n'))
+	if err := writeObjectStream(&body, from, shas); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Post(url+pushEndpoint, "application/octet-stream", &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func TestHTTPPushRejectsIncompleteHistory(t *testing.T) {
+	t.Setenv("MINIGIT_AUTHOR_NAME", "Test")
+	t.Setenv("MINIGIT_AUTHOR_EMAIL", "test@example.com")
+	base := t.TempDir()
+	origin := newTestRepo(t, filepath.Join(base, "origin"))
+	first := testCommit(t, origin, "master", "", map[string]string{"README": "hello\n"})
+	local := newTestRepo(t, filepath.Join(t.TempDir(), "local"))
+	shas, err := objectsToSend(origin, []string{first}, func(string) bool { return false })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := copyObjects(origin, local, shas); err != nil {
+		t.Fatal(err)
+	}
+	second := testCommit(t, local, "topic", first, map[string]string{"NEWS": "not sent\n"})
+
+	server := httptest.NewServer(newRepoServer(base))
+	defer server.Close()
+	url := server.URL + "/origin"
+
+	// the commit alone, without its tree and blob
+	resp := rawPush(t, url, local, []refUpdate{{Name: "refs/heads/x", New: second}}, []string{second})
+	if resp.StatusCode == http.StatusOK {
+		t.Fatal("a push without the commit's tree was accepted")
+	}
+	if _, err := common.ReadRef(origin, "refs/heads/x"); err == nil {
+		t.Fatal("refs/heads/x was created from an incomplete push")
+	}
+
+	// a refused update is turned away before any object is stored
+	commit, err := common.ReadCommit(local, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp = rawPush(t, url, local, []refUpdate{{Name: "refs/heads/master", Old: first, New: second}}, []string{commit.Tree})
+	if resp.StatusCode == http.StatusOK {
+		t.Fatal("a push to the checked out branch was accepted")
+	}
+	if common.ObjectExists(origin, commit.Tree) {
+		t.Fatal("the objects of a refused push were stored")
+	}
+}
@@ -0,0 +1,131 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/hanzala211/mini-git/common"
+	"github.com/spf13/cobra"
+)
+
+// resolveNewValue turns the new value of an update into a sha
+func resolveNewValue(repoRoot string, rev string) (string, error) {
+	sha, err := common.ResolveRevision(repoRoot, rev)
+	if err != nil {
+		return "", fmt.Errorf("%s: not a valid SHA1", rev)
+	}
+	return sha, nil
+}
+
+// resolveOldValue turns the expected old value into a sha, "" and the zero
+// sha both mean the ref must not exist
+func resolveOldValue(repoRoot string, rev string) (string, error) {
+	if rev == "" || rev == common.ZeroSha {
+		return "", nil
+	}
+	return resolveNewValue(repoRoot, rev)
+}
+
+// queueStdinUpdates reads "update <ref> <new> [<old>]", "create <ref> <new>"
+// and "delete <ref> [<old>]" lines into the transaction
+func queueStdinUpdates(repoRoot string, transaction *common.RefTransaction, reason string) error {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		command, fields := fields[0], fields[1:]
+		switch {
+		case command == "update" && (len(fields) == 2 || len(fields) == 3):
+			newSha, err := resolveNewValue(repoRoot, fields[1])
+			if err != nil {
+				return err
+			}
+			if len(fields) == 2 {
+				transaction.Set(fields[0], newSha, reason)
+				continue
+			}
+			oldSha, err := resolveOldValue(repoRoot, fields[2])
+			if err != nil {
+				return err
+			}
+			transaction.Update(fields[0], newSha, oldSha, reason)
+		case command == "create" && len(fields) == 2:
+			newSha, err := resolveNewValue(repoRoot, fields[1])
+			if err != nil {
+				return err
+			}
+			transaction.Update(fields[0], newSha, "", reason)
+		case command == "delete" && (len(fields) == 1 || len(fields) == 2):
+			oldSha := ""
+			if len(fields) == 2 {
+				var err error
+				if oldSha, err = resolveNewValue(repoRoot, fields[1]); err != nil {
+					return err
+				}
+			}
+			transaction.Delete(fields[0], oldSha, reason)
+		default:
+			return fmt.Errorf("invalid update-ref --stdin line %q", scanner.Text())
+		}
+	}
+	return scanner.Err()
+}
+
+func UpdateRefCommand(cmd *cobra.Command, args []string) {
+	repoPath, err := common.FindRepoRoot()
+	if err != nil {
+		log.Fatal(err)
+	}
+	deleteFlag, _ := cmd.Flags().GetBool("delete")
+	stdin, _ := cmd.Flags().GetBool("stdin")
+	reason, _ := cmd.Flags().GetString("message")
+	if reason == "" {
+		reason = "update-ref"
+	}
+	transaction := common.NewRefTransaction(repoPath)
+	switch {
+	case stdin:
+		if len(args) > 0 {
+			log.Fatal("update-ref --stdin takes no arguments")
+		}
+		if err := queueStdinUpdates(repoPath, transaction, reason); err != nil {
+			log.Fatal(err)
+		}
+	case deleteFlag:
+		if len(args) < 1 || len(args) > 2 {
+			log.Fatal("usage: mini-git update-ref -d <ref> [<old>]")
+		}
+		oldSha := ""
+		if len(args) == 2 {
+			if oldSha, err = resolveNewValue(repoPath, args[1]); err != nil {
+				log.Fatal(err)
+			}
+		}
+		transaction.Delete(args[0], oldSha, reason)
+	default:
+		if len(args) < 2 || len(args) > 3 {
+			log.Fatal("usage: mini-git update-ref <ref> <new> [<old>]")
+		}
+		newSha, err := resolveNewValue(repoPath, args[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(args) == 2 {
+			transaction.Set(args[0], newSha, reason)
+			break
+		}
+		oldSha, err := resolveOldValue(repoPath, args[2])
+		if err != nil {
+			log.Fatal(err)
+		}
+		transaction.Update(args[0], newSha, oldSha, reason)
+	}
+	if err := transaction.Commit(); err != nil {
+		log.Fatal(err)
+	}
+}
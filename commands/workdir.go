@@ -0,0 +1,326 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/hanzala211/mini-git/common"
+)
+
+// treeIndex flattens a tree into path -> blob sha, an empty sha gives an empty index
+func treeIndex(repoRoot string, treeSha string) (common.Index, error) {
+	if treeSha == "" {
+		return make(common.Index), nil
+	}
+	return buildIndexFromTree(repoRoot, treeSha, "")
+}
+
+// commitIndex flattens the tree of a commit, an empty sha gives an empty index
+func commitIndex(repoRoot string, commitSha string) (common.Index, error) {
+	if commitSha == "" {
+		return make(common.Index), nil
+	}
+	commit, err := common.ReadCommit(repoRoot, commitSha)
+	if err != nil {
+		return nil, err
+	}
+	return treeIndex(repoRoot, commit.Tree)
+}
+
+// repoRelativePath turns a path given on the command line into the slash
+// separated form used in trees and the index
+func repoRelativePath(repoRoot string, path string) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	relative, err := filepath.Rel(repoRoot, absPath)
+	if err != nil || relative == ".." || strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside repository", path)
+	}
+	return filepath.ToSlash(relative), nil
+}
+
+// blobAtPath finds the blob sha of a path inside a tree, "" means the path is not there
+func blobAtPath(repoRoot string, treeSha string, path string) (string, error) {
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		treeData, err := common.ReadObject(repoRoot, treeSha)
+		if err != nil {
+			return "", err
+		}
+		entries, err := parseTreeToMap(treeData)
+		if err != nil {
+			return "", err
+		}
+		entry, ok := entries[part]
+		if !ok {
+			return "", nil
+		}
+		isTree := modeToType(entry.Mode) == common.TreeFile
+		if i == len(parts)-1 {
+			if isTree {
+				return "", nil
+			}
+			return entry.SHA, nil
+		}
+		if !isTree {
+			return "", nil
+		}
+		treeSha = entry.SHA
+	}
+	return "", nil
+}
+
+// worktreeSha hashes the working tree copy of a path without writing it, "" means it is missing.
+// Large files are hashed as the pointer they would be committed as.
+func worktreeSha(repoRoot string, lfs *lfsTracker, path string) (string, error) {
+	file, err := os.Open(filepath.Join(repoRoot, filepath.FromSlash(path)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+	if lfs.tracks(path) {
+		pointer, err := cleanFile(repoRoot, file, info.Size(), false)
+		if err != nil {
+			return "", err
+		}
+		return common.HashObject(pointer, common.BlobFile), nil
+	}
+	return common.HashObjectFromReader(file, info.Size(), common.BlobFile)
+}
+
+// localChanges lists tracked paths whose working tree copy differs from the
+// index, or whose index entry differs from HEAD
+func localChanges(repoRoot string) ([]string, error) {
+	index, err := common.ReadIndex(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	headSha, err := common.GetParentSha(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	head, err := commitIndex(repoRoot, headSha)
+	if err != nil {
+		return nil, err
+	}
+	lfs, err := loadLFSTracker(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	changed := make(map[string]bool)
+	for path, sha := range index {
+		if head[path] != sha {
+			changed[path] = true
+			continue
+		}
+		current, err := worktreeSha(repoRoot, lfs, path)
+		if err != nil {
+			return nil, err
+		}
+		if current != sha {
+			changed[path] = true
+		}
+	}
+	for path := range head {
+		if _, ok := index[path]; !ok {
+			changed[path] = true
+		}
+	}
+	paths := make([]string, 0, len(changed))
+	for path := range changed {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// ensureClean fails when there are local changes that an operation would overwrite
+func ensureClean(repoRoot string, operation string) error {
+	changed, err := localChanges(repoRoot)
+	if err != nil {
+		return err
+	}
+	if len(changed) > 0 {
+		return fmt.Errorf("your local changes to %s would be overwritten by %s, commit or stash them first", changed[0], operation)
+	}
+	return nil
+}
+
+// updateWorktree moves the working tree from oldIndex to newIndex, removing
+// paths that are gone and writing paths that are new or changed
+func updateWorktree(repoRoot string, oldIndex common.Index, newIndex common.Index) error {
+	lfs, err := indexLFSTracker(repoRoot, newIndex)
+	if err != nil {
+		return err
+	}
+	for path := range oldIndex {
+		if _, ok := newIndex[path]; ok {
+			continue
+		}
+		fullPath := filepath.Join(repoRoot, filepath.FromSlash(path))
+		if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove file: %w", err)
+		}
+		removeEmptyDirs(repoRoot, filepath.Dir(fullPath))
+	}
+	for path, sha := range newIndex {
+		fullPath := filepath.Join(repoRoot, filepath.FromSlash(path))
+		if oldIndex[path] == sha {
+			if _, err := os.Stat(fullPath); err == nil {
+				continue
+			}
+		}
+		if err := writeBlobToWorktree(repoRoot, lfs, sha, fullPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeBlobToWorktree streams a blob into fullPath, creating its directory.
+// A large file pointer at a path lfs tracks is replaced by its content.
+func writeBlobToWorktree(repoRoot string, lfs *lfsTracker, blobSha string, fullPath string) error {
+	blob, err := common.OpenObject(repoRoot, blobSha)
+	if err != nil {
+		return fmt.Errorf("failed to read blob %s: %w", blobSha, err)
+	}
+	defer blob.Close()
+	content, _, closer, err := smudgeBlob(repoRoot, lfs, blob, fullPath)
+	if err != nil {
+		return fmt.Errorf("failed to read blob %s: %w", blobSha, err)
+	}
+	if closer != nil {
+		defer closer.Close()
+	}
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	file, err := os.OpenFile(fullPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if _, err := io.Copy(file, content); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
+
+// removeEmptyDirs removes dir and its parents while they are empty, stopping at the repo root
+func removeEmptyDirs(repoRoot string, dir string) {
+	for dir != repoRoot && len(dir) > len(repoRoot) {
+		if err := os.Remove(dir); err != nil { // fails for non-empty directories
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// applyMergeResult writes a merge to the working tree and index, starting from
+// the state described by current, conflicted paths get their marker content
+func applyMergeResult(repoRoot string, current common.Index, result *mergeResult) error {
+	if err := updateWorktree(repoRoot, current, result.Index); err != nil {
+		return err
+	}
+	for path, content := range result.Worktree {
+		fullPath := filepath.Join(repoRoot, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := os.WriteFile(fullPath, content, 0644); err != nil {
+			return fmt.Errorf("failed to write file: %w", err)
+		}
+	}
+	return common.WriteIndex(repoRoot, result.Index)
+}
+
+// resetHard makes the index and the tracked files match a commit, extraPaths
+// are removed too when the commit does not have them (e.g. leftover conflict files)
+func resetHard(repoRoot string, commitSha string, extraPaths []string) error {
+	target, err := commitIndex(repoRoot, commitSha)
+	if err != nil {
+		return err
+	}
+	current, err := common.ReadIndex(repoRoot)
+	if err != nil {
+		return err
+	}
+	lfs, err := indexLFSTracker(repoRoot, target)
+	if err != nil {
+		return err
+	}
+	for path, sha := range target {
+		onDisk, err := worktreeSha(repoRoot, lfs, path)
+		if err != nil {
+			return err
+		}
+		if onDisk != sha {
+			if err := writeBlobToWorktree(repoRoot, lfs, sha, filepath.Join(repoRoot, filepath.FromSlash(path))); err != nil {
+				return err
+			}
+		}
+	}
+	stale := append(extraPaths, slices.Collect(maps.Keys(current))...)
+	for _, path := range stale {
+		if _, ok := target[path]; ok {
+			continue
+		}
+		fullPath := filepath.Join(repoRoot, filepath.FromSlash(path))
+		if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove file: %w", err)
+		}
+		removeEmptyDirs(repoRoot, filepath.Dir(fullPath))
+	}
+	return common.WriteIndex(repoRoot, target)
+}
+
+// unresolvedConflicts returns the conflicted paths that still have conflict
+// markers or whose resolution has not been added to the index yet
+func unresolvedConflicts(repoRoot string, paths []string) ([]string, error) {
+	index, err := common.ReadIndex(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	var unresolved []string
+	for _, path := range paths {
+		content, err := os.ReadFile(filepath.Join(repoRoot, filepath.FromSlash(path)))
+		if os.IsNotExist(err) {
+			continue // resolved by deleting it, commit drops missing files from the index
+		}
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Contains(content, []byte("<<<<<<< ")) || index[path] != common.HashObject(content, common.BlobFile) {
+			unresolved = append(unresolved, path)
+		}
+	}
+	return unresolved, nil
+}
+
+// dropMissingFromIndex removes entries whose file no longer exists, like commit does
+func dropMissingFromIndex(repoRoot string, index common.Index) {
+	for filePath := range index {
+		if _, err := os.Stat(filepath.Join(repoRoot, filePath)); os.IsNotExist(err) {
+			delete(index, filePath)
+		}
+	}
+}
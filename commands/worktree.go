@@ -0,0 +1,398 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/hanzala211/mini-git/common"
+	"github.com/spf13/cobra"
+)
+
+// a linked worktree is a second working directory of the same repository.
+// Its .minigit file points to .minigit/worktrees/<name> in the main one,
+// which holds its HEAD and index, see common/worktree.go.
+
+// worktreeBranches returns the branches a worktree has in use: the one HEAD
+// is on and the one a rebase or bisect in it will return to
+func worktreeBranches(repoRoot string, worktree common.Worktree) ([]string, error) {
+	refName, _, err := worktree.Head(repoRoot)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	branches := []string{refName}
+	for _, state := range []string{filepath.Join(rebaseDir, "head-name"), filepath.Join(bisectDir, "start")} {
+		if content, err := os.ReadFile(filepath.Join(worktree.MinigitDir, state)); err == nil {
+			branches = append(branches, strings.TrimSpace(string(content)))
+		}
+	}
+	return branches, nil
+}
+
+// worktreeWithBranch returns the path of another worktree that has branchRef
+// in use, "" when no other worktree does
+func worktreeWithBranch(repoRoot string, branchRef string) (string, error) {
+	worktrees, err := common.ListWorktrees(repoRoot)
+	if err != nil {
+		return "", err
+	}
+	own := common.MinigitDir(repoRoot)
+	for _, worktree := range worktrees {
+		if worktree.MinigitDir == own {
+			continue
+		}
+		branches, err := worktreeBranches(repoRoot, worktree)
+		if err != nil {
+			return "", err
+		}
+		if slices.Contains(branches, branchRef) {
+			return worktreeDisplayPath(worktree), nil
+		}
+	}
+	return "", nil
+}
+
+// ensureBranchFree fails when another worktree already has the branch checked out
+func ensureBranchFree(repoRoot string, branchRef string) error {
+	path, err := worktreeWithBranch(repoRoot, branchRef)
+	if err != nil {
+		return err
+	}
+	if path != "" {
+		return fmt.Errorf("'%s' is already checked out at '%s'", strings.TrimPrefix(branchRef, "refs/heads/"), path)
+	}
+	return nil
+}
+
+func worktreeDisplayPath(worktree common.Worktree) string {
+	if worktree.Path == "" {
+		return worktree.MinigitDir
+	}
+	return worktree.Path
+}
+
+// findWorktree looks a linked worktree up by its path or name
+func findWorktree(repoRoot string, arg string) (common.Worktree, error) {
+	worktrees, err := common.ListWorktrees(repoRoot)
+	if err != nil {
+		return common.Worktree{}, err
+	}
+	absPath, err := filepath.Abs(arg)
+	if err != nil {
+		return common.Worktree{}, err
+	}
+	for i, worktree := range worktrees {
+		if worktree.Path != absPath && worktree.Name != arg {
+			continue
+		}
+		if i == 0 {
+			return common.Worktree{}, fmt.Errorf("'%s' is the main worktree", arg)
+		}
+		return worktree, nil
+	}
+	return common.Worktree{}, fmt.Errorf("'%s' is not a worktree", arg)
+}
+
+// newWorktreeName picks a free folder name under .minigit/worktrees from the
+// base name of the path, adding a number when it is taken
+func newWorktreeName(worktreesDir string, path string) string {
+	base := filepath.Base(path)
+	name := base
+	for i := 1; ; i++ {
+		if _, err := os.Stat(filepath.Join(worktreesDir, name)); os.IsNotExist(err) {
+			return name
+		}
+		name = fmt.Sprintf("%s%d", base, i)
+	}
+}
+
+// worktreeTarget works out what worktree add checks out from its arguments:
+// the branch HEAD goes on ("" for a detached HEAD) and the commit
+func worktreeTarget(repoRoot string, path string, commitish string, newBranch string, detach bool) (string, string, error) {
+	if newBranch != "" {
+		start := commitish
+		if start == "" {
+			start = common.HEAD
+		}
+		sha, err := common.ResolveCommit(repoRoot, start)
+		return "refs/heads/" + newBranch, sha, err
+	}
+	if commitish == "" {
+		if detach {
+			sha, err := common.ResolveRevision(repoRoot, common.HEAD)
+			return "", sha, err
+		}
+		commitish = filepath.Base(path) // like git, a branch named after the folder
+		if _, err := common.ReadRef(repoRoot, "refs/heads/"+commitish); err != nil {
+			sha, err := common.ResolveRevision(repoRoot, common.HEAD)
+			return "refs/heads/" + commitish, sha, err
+		}
+	}
+	if !detach {
+		if sha, err := common.ReadRef(repoRoot, "refs/heads/"+commitish); err == nil {
+			if sha == "" {
+				return "", "", fmt.Errorf("branch %s has no commits yet", commitish)
+			}
+			return "refs/heads/" + commitish, sha, nil
+		}
+	}
+	sha, err := common.ResolveCommit(repoRoot, commitish)
+	return "", sha, err
+}
+
+// addWorktree creates the worktree folder and the working directory linked to
+// it, then checks out sha there. On failure the caller removes both again.
+func addWorktree(repoRoot string, path string, minigitDir string, branchRef string, sha string) error {
+	if err := os.MkdirAll(minigitDir, 0755); err != nil {
+		return err
+	}
+	head := sha
+	if branchRef != "" {
+		head = "ref: " + branchRef
+	}
+	relCommonDir, err := filepath.Rel(minigitDir, common.CommonDir(repoRoot))
+	if err != nil {
+		return err
+	}
+	files := map[string]string{
+		common.CommonDirFile:  relCommonDir,
+		common.MinigitDirFile: filepath.Join(path, common.RootDir),
+		common.HEAD:           head,
+		common.IndexFile:      "{}",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(minigitDir, name), []byte(content+"\n"), 0644); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return err
+	}
+	if err := common.WriteMinigitLink(filepath.Join(path, common.RootDir), minigitDir); err != nil {
+		return err
+	}
+	// from here on path is a repository of its own that shares our objects
+	if err := switchWorktree(path, sha); err != nil {
+		return err
+	}
+	return common.AppendReflog(path, common.HEAD, "", sha, "worktree add: "+filepath.Base(path))
+}
+
+func worktreeAdd(cmd *cobra.Command, repoRoot string, args []string) {
+	if len(args) < 2 || len(args) > 3 {
+		log.Fatal("usage: mini-git worktree add [-b <new-branch>] [--detach] <path> [<commit-ish>]")
+	}
+	newBranch, _ := cmd.Flags().GetString("branch")
+	detach, _ := cmd.Flags().GetBool("detach")
+	force, _ := cmd.Flags().GetBool("force")
+	path, err := filepath.Abs(args[1])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if entries, err := os.ReadDir(path); err == nil && len(entries) > 0 {
+		log.Fatalf("'%s' already exists", args[1])
+	} else if err != nil && !os.IsNotExist(err) {
+		log.Fatalf("'%s' already exists", args[1])
+	}
+	commitish := ""
+	if len(args) == 3 {
+		commitish = args[2]
+	}
+	branchRef, sha, err := worktreeTarget(repoRoot, path, commitish, newBranch, detach)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if sha == "" {
+		log.Fatal("no commit to check out, make a first commit before adding a worktree")
+	}
+	createBranch := false
+	if branchRef != "" {
+		_, err := common.ReadRef(repoRoot, branchRef)
+		createBranch = err != nil
+	}
+	if newBranch != "" && !createBranch {
+		log.Fatalf("branch %s already exists", newBranch)
+	}
+	if branchRef != "" && !createBranch && !force {
+		if err := ensureBranchFree(repoRoot, branchRef); err != nil {
+			log.Fatal(err)
+		}
+		// ensureBranchFree only looks at the other worktrees, this one counts here
+		if headRef, err := common.GetHeadRef(repoRoot); err == nil && headRef == branchRef {
+			log.Fatalf("'%s' is already checked out at '%s'", strings.TrimPrefix(branchRef, "refs/heads/"), repoRoot)
+		}
+	}
+	if createBranch {
+		// the empty old value makes the update fail if the branch appeared in the meantime
+		transaction := common.NewRefTransaction(repoRoot)
+		from := commitish
+		if from == "" {
+			from = common.HEAD
+		}
+		transaction.Update(branchRef, sha, "", "branch: Created from "+from)
+		if err := transaction.Commit(); err != nil {
+			log.Fatalf("failed to create branch %s: %v", strings.TrimPrefix(branchRef, "refs/heads/"), err)
+		}
+	}
+
+	worktreesDir := filepath.Join(common.CommonDir(repoRoot), common.Worktrees)
+	minigitDir := filepath.Join(worktreesDir, newWorktreeName(worktreesDir, path))
+	if branchRef != "" {
+		fmt.Printf("Preparing worktree (checking out '%s')\n", strings.TrimPrefix(branchRef, "refs/heads/"))
+	} else {
+		fmt.Printf("Preparing worktree (detached HEAD %s)\n", sha[:7])
+	}
+	_, pathErr := os.Stat(path)
+	if err := addWorktree(repoRoot, path, minigitDir, branchRef, sha); err != nil {
+		os.RemoveAll(minigitDir)
+		if os.IsNotExist(pathErr) {
+			os.RemoveAll(path)
+		}
+		log.Fatalf("failed to add worktree: %v", err)
+	}
+	commit, err := common.ReadCommit(repoRoot, sha)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("HEAD is now at %s %s\n", sha[:7], firstLine(commit.Message))
+	if err := runHook(path, "post-checkout", common.ZeroSha, sha, "1"); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func worktreeList(repoRoot string) {
+	worktrees, err := common.ListWorktrees(repoRoot)
+	if err != nil {
+		log.Fatal(err)
+	}
+	width := 0
+	for _, worktree := range worktrees {
+		width = max(width, len(worktreeDisplayPath(worktree)))
+	}
+	for _, worktree := range worktrees {
+		refName, sha, err := worktree.Head(repoRoot)
+		if err != nil && !os.IsNotExist(err) {
+			log.Fatal(err)
+		}
+		short := strings.Repeat("0", 7)
+		if len(sha) >= 7 {
+			short = sha[:7]
+		}
+		line := fmt.Sprintf("%-*s  %s ", width, worktreeDisplayPath(worktree), short)
+		if refName != "" {
+			line += "[" + strings.TrimPrefix(refName, "refs/heads/") + "]"
+		} else {
+			line += "(detached HEAD)"
+		}
+		if worktree.Prunable() != "" {
+			line += " prunable"
+		}
+		fmt.Println(line)
+	}
+}
+
+// untrackedFiles returns the files in a working tree its index does not have
+func untrackedFiles(repoRoot string) ([]string, error) {
+	index, err := common.ReadIndex(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	var untracked []string
+	for _, path := range collectAddPaths(repoRoot, []string{repoRoot}) {
+		relPath, err := repoRelativePath(repoRoot, path)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := index[relPath]; !ok {
+			untracked = append(untracked, relPath)
+		}
+	}
+	return untracked, nil
+}
+
+// worktreeRemove deletes a linked worktree, refusing while it has changes
+// that would be lost unless forced
+func worktreeRemove(cmd *cobra.Command, repoRoot string, args []string) {
+	if len(args) != 2 {
+		log.Fatal("usage: mini-git worktree remove [--force] <worktree>")
+	}
+	force, _ := cmd.Flags().GetBool("force")
+	worktree, err := findWorktree(repoRoot, args[1])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if worktree.MinigitDir == common.MinigitDir(repoRoot) {
+		log.Fatal("cannot remove the current worktree, run this from another one")
+	}
+	if worktree.Prunable() == "" && !force {
+		changed, err := localChanges(worktree.Path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		untracked, err := untrackedFiles(worktree.Path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(changed) > 0 || len(untracked) > 0 {
+			log.Fatalf("'%s' contains modified or untracked files, use --force to delete it", args[1])
+		}
+	}
+	if worktree.Path != "" && worktree.Prunable() == "" {
+		if err := os.RemoveAll(worktree.Path); err != nil {
+			log.Fatalf("failed to remove worktree: %v", err)
+		}
+	}
+	if err := os.RemoveAll(worktree.MinigitDir); err != nil {
+		log.Fatalf("failed to remove worktree: %v", err)
+	}
+	os.Remove(filepath.Dir(worktree.MinigitDir)) // only succeeds once no worktree is left
+	fmt.Printf("Removed worktree %s\n", worktreeDisplayPath(worktree))
+}
+
+// worktreePrune removes the folders of linked worktrees whose working
+// directory was deleted or moved by hand
+func worktreePrune(repoRoot string, dryRun bool) {
+	worktrees, err := common.ListWorktrees(repoRoot)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, worktree := range worktrees {
+		reason := worktree.Prunable()
+		if reason == "" {
+			continue
+		}
+		fmt.Printf("Removing %s/%s: %s\n", common.Worktrees, worktree.Name, reason)
+		if dryRun {
+			continue
+		}
+		if err := os.RemoveAll(worktree.MinigitDir); err != nil {
+			log.Fatalf("failed to prune worktree: %v", err)
+		}
+	}
+	if !dryRun {
+		os.Remove(filepath.Join(common.CommonDir(repoRoot), common.Worktrees)) // only succeeds once no worktree is left
+	}
+}
+
+func WorktreeCommand(cmd *cobra.Command, args []string) {
+	repoPath, err := common.FindRepoRoot()
+	if err != nil {
+		log.Fatal(err)
+	}
+	switch args[0] {
+	case "add":
+		worktreeAdd(cmd, repoPath, args)
+	case "list":
+		worktreeList(repoPath)
+	case "remove":
+		worktreeRemove(cmd, repoPath, args)
+	case "prune":
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		worktreePrune(repoPath, dryRun)
+	default:
+		log.Fatalf("unknown worktree command %q, expected add, list, remove or prune", args[0])
+	}
+}
@@ -0,0 +1,25 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/hanzala211/mini-git/common"
+	"github.com/spf13/cobra"
+)
+
+func WriteTreeCommand(cmd *cobra.Command, args []string) {
+	repoPath, err := common.FindRepoRoot()
+	if err != nil {
+		log.Fatal(err)
+	}
+	index, err := common.ReadIndex(repoPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	treeSha, err := buildTree(repoPath, index)
+	if err != nil {
+		log.Fatalf("failed to build trees: %v", err)
+	}
+	fmt.Println(treeSha)
+}
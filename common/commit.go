@@ -0,0 +1,188 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// commit objects look like this:
+//
+//	tree <sha>
+//	parent <sha>        (zero or more)
+//	author <name> <<email>> <unix seconds> <zone>   (older commits have none)
+//	gpgsig <armored signature, continued on lines starting with a space>   (signed commits only)
+//
+//	<message>
+//	<unix seconds> <zone>
+type Commit struct {
+	Tree      string
+	Parents   []string
+	Author    string
+	Message   string
+	Timestamp string
+	Signature string // armored SSH signature over Payload, empty when unsigned
+}
+
+var timestampLine = regexp.MustCompile(`^\d+ [+-]\d{4}$`)
+
+func Timestamp(t time.Time) string {
+	return fmt.Sprintf("%d %s", t.Unix(), t.Format("-0700"))
+}
+
+// ParseTimestamp turns a "<unix seconds> <zone>" string back into a time
+func ParseTimestamp(timestamp string) (time.Time, error) {
+	var seconds int64
+	var zone string
+	if _, err := fmt.Sscanf(timestamp, "%d %s", &seconds, &zone); err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q", timestamp)
+	}
+	t := time.Unix(seconds, 0)
+	if parsedZone, err := time.Parse("-0700", zone); err == nil {
+		t = t.In(parsedZone.Location())
+	}
+	return t, nil
+}
+
+func ParseCommit(data []byte) (*Commit, error) {
+	commit := &Commit{}
+	headers, body, found := strings.Cut(string(data), "\n\n")
+	if !found {
+		headers = strings.TrimSuffix(string(data), "\n")
+	}
+	lastKey := ""
+	for _, line := range strings.Split(headers, "\n") {
+		if continued, ok := strings.CutPrefix(line, " "); ok {
+			if lastKey == "gpgsig" {
+				commit.Signature += "\n" + continued
+			}
+			continue
+		}
+		key, value, _ := strings.Cut(line, " ")
+		lastKey = key
+		switch key {
+		case "tree":
+			commit.Tree = strings.TrimSpace(value)
+		case "parent":
+			commit.Parents = append(commit.Parents, strings.TrimSpace(value))
+		case "author":
+			commit.Author = value
+		case "gpgsig":
+			commit.Signature = value
+		}
+	}
+	if commit.Tree == "" {
+		return nil, fmt.Errorf("invalid commit: missing tree")
+	}
+	body = strings.TrimSuffix(body, "\n")
+	if i := strings.LastIndexByte(body, '\n'); i != -1 && timestampLine.MatchString(body[i+1:]) {
+		commit.Message, commit.Timestamp = body[:i], body[i+1:]
+	} else if timestampLine.MatchString(body) {
+		commit.Timestamp = body
+	} else {
+		commit.Message = body
+	}
+	return commit, nil
+}
+
+func (c *Commit) Bytes() []byte {
+	var content bytes.Buffer
+	fmt.Fprintf(&content, "tree %s\n", c.Tree)
+	for _, parent := range c.Parents {
+		fmt.Fprintf(&content, "parent %s\n", parent)
+	}
+	if c.Author != "" {
+		fmt.Fprintf(&content, "author %s\n", c.Author)
+	}
+	if c.Signature != "" {
+		fmt.Fprintf(&content, "gpgsig %s\n", strings.ReplaceAll(strings.TrimSuffix(c.Signature, "\n"), "\n", "\n "))
+	}
+	fmt.Fprintf(&content, "\n%s\n", c.Message)
+	fmt.Fprintf(&content, "%s\n", c.Timestamp)
+	return content.Bytes()
+}
+
+func ReadCommit(repoRoot string, commitSha string) (*Commit, error) {
+	objType, data, err := ReadObjectWithType(repoRoot, commitSha)
+	if err != nil {
+		return nil, err
+	}
+	if objType != CommitFile {
+		return nil, fmt.Errorf("object %s is a %s, not a commit", commitSha, objType)
+	}
+	return ParseCommit(data)
+}
+
+// Payload is what the signature of a new commit covers: the commit without
+// its signature. Stored commits are verified against SignedCommitPayload.
+func (c *Commit) Payload() []byte {
+	unsigned := *c
+	unsigned.Signature = ""
+	return unsigned.Bytes()
+}
+
+// SetDefaults fills in the timestamp and author of a new commit, it has to
+// happen before the commit is signed
+func (c *Commit) SetDefaults() {
+	if c.Timestamp == "" {
+		c.Timestamp = Timestamp(time.Now())
+	}
+	if c.Author == "" {
+		c.Author = Identity() + " " + c.Timestamp
+	}
+}
+
+func WriteCommit(repoRoot string, commit *Commit) (string, error) {
+	commit.SetDefaults()
+	return WriteObject(repoRoot, commit.Bytes(), CommitFile, "")
+}
+
+// AuthorName returns the "Name <email>" part of the author header
+func (c *Commit) AuthorName() string {
+	if i := strings.LastIndexByte(c.Author, '>'); i != -1 {
+		return c.Author[:i+1]
+	}
+	return c.Author
+}
+
+// AuthorTime returns when the commit was authored, commits without an author
+// header fall back to the commit timestamp
+func (c *Commit) AuthorTime() (time.Time, error) {
+	if i := strings.LastIndexByte(c.Author, '>'); i != -1 {
+		return ParseTimestamp(strings.TrimSpace(c.Author[i+1:]))
+	}
+	return ParseTimestamp(c.Timestamp)
+}
+
+// SignedCommitPayload returns what the signature of a stored commit covers:
+// its bytes as stored with only the gpgsig header removed, like git. Headers
+// the parser does not know stay in, so no other change keeps the signature valid.
+func SignedCommitPayload(data []byte) []byte {
+	headerEnd := bytes.Index(data, []byte("\n\n")) + 1 // every header line keeps its newline
+	if headerEnd == 0 {
+		headerEnd = len(data)
+	}
+	payload := make([]byte, 0, len(data))
+	inSignature := false
+	for start := 0; start < headerEnd; {
+		end := headerEnd
+		if i := bytes.IndexByte(data[start:headerEnd], '\n'); i != -1 {
+			end = start + i + 1
+		}
+		line := data[start:end]
+		if bytes.HasPrefix(line, []byte(" ")) {
+			if !inSignature {
+				payload = append(payload, line...)
+			}
+		} else {
+			inSignature = bytes.HasPrefix(line, []byte("gpgsig "))
+			if !inSignature {
+				payload = append(payload, line...)
+			}
+		}
+		start = end
+	}
+	return append(payload, data[headerEnd:]...)
+}
@@ -0,0 +1,61 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Config is the repository configuration kept in .minigit/config.json
+type Config struct {
+	Remotes  map[string]RemoteConfig `json:"remotes,omitempty"`
+	Branches map[string]BranchConfig `json:"branches,omitempty"`
+	Signing  *SigningConfig          `json:"signing,omitempty"`
+}
+
+type RemoteConfig struct {
+	URL string `json:"url"`
+}
+
+// BranchConfig is the upstream a local branch tracks
+type BranchConfig struct {
+	Remote string `json:"remote"`
+	Merge  string `json:"merge"` // the branch on the remote, e.g. refs/heads/master
+}
+
+// SigningConfig is how commits and tags are signed and whose signatures are trusted
+type SigningConfig struct {
+	Key            string `json:"key,omitempty"`            // private key file
+	AllowedSigners string `json:"allowedSigners,omitempty"` // defaults to .minigit/allowed_signers
+	Sign           bool   `json:"sign,omitempty"`           // sign every commit and annotated tag
+}
+
+// ReadConfig returns the configuration, a missing file is an empty configuration
+func ReadConfig(repoRoot string) (*Config, error) {
+	config := &Config{}
+	content, err := os.ReadFile(filepath.Join(CommonDir(repoRoot), ConfigFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(content, config); err != nil {
+			return nil, fmt.Errorf("failed to parse config: %w", err)
+		}
+	}
+	if config.Remotes == nil {
+		config.Remotes = make(map[string]RemoteConfig)
+	}
+	if config.Branches == nil {
+		config.Branches = make(map[string]BranchConfig)
+	}
+	return config, nil
+}
+
+func WriteConfig(repoRoot string, config *Config) error {
+	content, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+	return WriteFileAtomic(filepath.Join(CommonDir(repoRoot), ConfigFile), append(content, '\n'))
+}
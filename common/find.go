@@ -2,6 +2,7 @@ package common
 
 import (
 	"errors"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
@@ -19,6 +20,16 @@ func FindRepoRoot() (string, error) {
 		if err == nil && stat.IsDir() { // checks if the cwd has .minigit folder IsDir returns true if found
 			return cwd, nil
 		}
+		if err == nil { // a linked worktree has a .minigit file pointing to its folder in the main repository
+			target, err := ReadMinigitLink(repoPath)
+			if err != nil {
+				return "", err
+			}
+			if stat, err := os.Stat(target); err != nil || !stat.IsDir() {
+				return "", fmt.Errorf("%s points to %s, which is not a worktree folder", repoPath, target)
+			}
+			return cwd, nil
+		}
 
 		if cwd == filepath.Dir(cwd){ // to check if we had reached the top level like /home == / false next time this will be / == / so that will tell us that we are at system path
 			return "", errors.New("not a mini-git repository")
@@ -0,0 +1,62 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+func ReadIndex(repoRoot string) (Index, error) {
+	indexBytes, err := os.ReadFile(indexPath(repoRoot))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+	index := make(Index)
+	if err := json.Unmarshal(indexBytes, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse index: %w", err)
+	}
+	if index == nil { // an index containing "null"
+		index = make(Index)
+	}
+	return index, nil
+}
+
+func indexPath(repoRoot string) string {
+	return filepath.Join(MinigitDir(repoRoot), IndexFile)
+}
+
+// WriteIndex replaces the index atomically under index.json.lock
+func WriteIndex(repoRoot string, index Index) error {
+	indexBytes, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal index: %w", err)
+	}
+	return WriteFileAtomic(indexPath(repoRoot), indexBytes)
+}
+
+// UpdateIndex reads the index, lets update change it and writes it back, all
+// under the index lock so a concurrent change is never lost. Nothing is
+// written if update fails.
+func UpdateIndex(repoRoot string, update func(index Index) error) error {
+	lock, err := Lock(indexPath(repoRoot))
+	if err != nil {
+		return err
+	}
+	index, err := ReadIndex(repoRoot)
+	if err == nil {
+		err = update(index)
+	}
+	var indexBytes []byte
+	if err == nil {
+		indexBytes, err = json.MarshalIndent(index, "", "  ")
+	}
+	if err == nil {
+		err = lock.Write(indexBytes)
+	}
+	if err != nil {
+		lock.Rollback()
+		return err
+	}
+	return lock.Commit()
+}
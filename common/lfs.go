@@ -0,0 +1,210 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// a large file is committed as a small pointer blob in the git-lfs format
+//
+//	version https://git-lfs.github.com/spec/v1
+//	oid sha256:<sha256 of the content>
+//	size <bytes>
+//
+// while the content itself is kept uncompressed in .minigit/lfs/objects/<oid[:2]>/<oid[2:4]>/<oid>
+const lfsPointerVersion = "version https://git-lfs.github.com/spec/v1"
+
+// LFSPointerMaxSize is the largest blob that is looked at as a possible pointer
+const LFSPointerMaxSize = 1024
+
+type LFSPointer struct {
+	Oid  string
+	Size int64
+}
+
+func (p LFSPointer) Bytes() []byte {
+	return []byte(fmt.Sprintf("%s\noid sha256:%s\nsize %d\n", lfsPointerVersion, p.Oid, p.Size))
+}
+
+// ParseLFSPointer reports whether data is a pointer and returns it
+func ParseLFSPointer(data []byte) (LFSPointer, bool) {
+	if len(data) > LFSPointerMaxSize {
+		return LFSPointer{}, false
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 3 || lines[0] != lfsPointerVersion {
+		return LFSPointer{}, false
+	}
+	oid, ok := strings.CutPrefix(lines[1], "oid sha256:")
+	if !ok || !validLFSOid(oid) {
+		return LFSPointer{}, false
+	}
+	sizeStr, ok := strings.CutPrefix(lines[2], "size ")
+	size, err := strconv.ParseInt(sizeStr, 10, 64)
+	if !ok || err != nil || size < 0 {
+		return LFSPointer{}, false
+	}
+	return LFSPointer{Oid: oid, Size: size}, true
+}
+
+// validLFSOid accepts only a lowercase hex sha256, the oid becomes a path in the store
+func validLFSOid(oid string) bool {
+	if len(oid) != sha256.Size*2 || oid != strings.ToLower(oid) {
+		return false
+	}
+	_, err := hex.DecodeString(oid)
+	return err == nil
+}
+
+// ReadLFSPointer reads a pointer from r, which only succeeds when r holds
+// nothing but a pointer. A file already in pointer form is cleaned to itself.
+func ReadLFSPointer(r io.Reader) (LFSPointer, bool) {
+	data, err := io.ReadAll(io.LimitReader(r, LFSPointerMaxSize+1))
+	if err != nil {
+		return LFSPointer{}, false
+	}
+	return ParseLFSPointer(data)
+}
+
+func lfsObjectsDir(repoRoot string) string {
+	return filepath.Join(CommonDir(repoRoot), LFSDir, ObjectDir)
+}
+
+func lfsObjectPath(repoRoot string, oid string) string {
+	return filepath.Join(lfsObjectsDir(repoRoot), oid[:2], oid[2:4], oid)
+}
+
+// HashLFSContent returns the pointer for the content of r without storing it
+func HashLFSContent(r io.Reader) (LFSPointer, error) {
+	hash := sha256.New()
+	size, err := io.Copy(hash, r)
+	if err != nil {
+		return LFSPointer{}, err
+	}
+	return LFSPointer{Oid: fmt.Sprintf("%x", hash.Sum(nil)), Size: size}, nil
+}
+
+// WriteLFSObject streams r into the large file store and returns its pointer,
+// like objects it goes to a temporary file that is renamed once the oid is known
+func WriteLFSObject(repoRoot string, r io.Reader) (LFSPointer, error) {
+	objectsDir := lfsObjectsDir(repoRoot)
+	if err := os.MkdirAll(objectsDir, 0755); err != nil {
+		return LFSPointer{}, err
+	}
+	tmpFile, err := os.CreateTemp(objectsDir, "tmp_lfs_")
+	if err != nil {
+		return LFSPointer{}, err
+	}
+	defer func() {
+		tmpFile.Close()
+		os.Remove(tmpFile.Name()) // fails harmlessly once it was renamed
+	}()
+	pointer, err := HashLFSContent(io.TeeReader(r, tmpFile))
+	if err != nil {
+		return LFSPointer{}, fmt.Errorf("failed to store large file: %w", err)
+	}
+	if LFSObjectExists(repoRoot, pointer.Oid) {
+		return pointer, nil
+	}
+	if err := tmpFile.Close(); err != nil {
+		return LFSPointer{}, err
+	}
+	objPath := lfsObjectPath(repoRoot, pointer.Oid)
+	if err := os.MkdirAll(filepath.Dir(objPath), 0755); err != nil {
+		return LFSPointer{}, err
+	}
+	if err := os.Chmod(tmpFile.Name(), 0644); err != nil {
+		return LFSPointer{}, err
+	}
+	if err := os.Rename(tmpFile.Name(), objPath); err != nil {
+		return LFSPointer{}, err
+	}
+	return pointer, nil
+}
+
+func LFSObjectExists(repoRoot string, oid string) bool {
+	_, err := os.Stat(lfsObjectPath(repoRoot, oid))
+	return err == nil
+}
+
+// OpenLFSObject opens the content of a large file, the caller has to Close it
+func OpenLFSObject(repoRoot string, oid string) (*os.File, error) {
+	return os.Open(lfsObjectPath(repoRoot, oid))
+}
+
+// OpenLFSContent opens the content of pointer after checking its size and
+// sha256, so a damaged or swapped store file is never checked out. A missing
+// file returns the not exist error of os.Open, the caller has to Close it.
+func OpenLFSContent(repoRoot string, pointer LFSPointer) (*os.File, error) {
+	file, err := OpenLFSObject(repoRoot, pointer.Oid)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := HashLFSContent(file)
+	if err == nil && stored != pointer {
+		err = fmt.Errorf("large file %s in the store does not match its pointer", pointer.Oid)
+	}
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// ListLFSObjects returns the oid of every large file in the store
+func ListLFSObjects(repoRoot string) ([]string, error) {
+	var oids []string
+	err := filepath.WalkDir(lfsObjectsDir(repoRoot), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !entry.IsDir() && validLFSOid(entry.Name()) {
+			oids = append(oids, entry.Name())
+		}
+		return nil
+	})
+	return oids, err
+}
+
+// RemoveLFSObject deletes a large file from the store along with its now empty folders
+func RemoveLFSObject(repoRoot string, oid string) error {
+	objPath := lfsObjectPath(repoRoot, oid)
+	if err := os.Remove(objPath); err != nil {
+		return err
+	}
+	os.Remove(filepath.Dir(objPath)) // only succeeds once the folder is empty
+	os.Remove(filepath.Dir(filepath.Dir(objPath)))
+	return nil
+}
+
+// ReadBlobLFSPointer returns the pointer an object holds, if it is one, without
+// reading objects that are not blobs or too large to be a pointer
+func ReadBlobLFSPointer(repoRoot string, blobSha string) (LFSPointer, bool, error) {
+	blob, err := OpenObject(repoRoot, blobSha)
+	if err != nil {
+		return LFSPointer{}, false, err
+	}
+	defer blob.Close()
+	if blob.Type != BlobFile || blob.Size > LFSPointerMaxSize {
+		return LFSPointer{}, false, nil
+	}
+	data, err := io.ReadAll(blob)
+	if err != nil {
+		return LFSPointer{}, false, err
+	}
+	pointer, ok := ParseLFSPointer(data)
+	return pointer, ok, nil
+}
@@ -0,0 +1,148 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const lockSuffix = ".lock"
+
+// lockTimeout is how long Lock waits for another process to release a lock,
+// they are only held for a single small write so anything longer is a stale lock
+const lockTimeout = time.Second
+
+// LockFile is an exclusively created "<path>.lock". Like git, the new content
+// is written to the lock file and renamed over path by Commit, so readers see
+// either the old or the new file and never a partial one, and a second writer
+// fails instead of overwriting the first.
+type LockFile struct {
+	path string
+	file *os.File
+}
+
+// LockError is returned when another process holds the lock
+type LockError struct {
+	Path string
+}
+
+func (e *LockError) Error() string {
+	return fmt.Sprintf("Unable to create '%s': File exists.\n\n"+
+		"Another mini-git process seems to be running in this repository, e.g.\n"+
+		"an editor opened by 'mini-git commit'. Please make sure all processes\n"+
+		"are terminated then try again. If it still fails, a mini-git process\n"+
+		"may have crashed in this repository earlier:\n"+
+		"remove the file manually to continue.", e.Path)
+}
+
+// held tracks the locks of this process so an interrupt does not leave them behind
+var held = struct {
+	sync.Mutex
+	locks   map[*LockFile]bool
+	signals chan os.Signal
+}{locks: make(map[*LockFile]bool)}
+
+func trackLock(lock *LockFile) {
+	held.Lock()
+	defer held.Unlock()
+	if len(held.locks) == 0 {
+		held.signals = make(chan os.Signal, 1)
+		signal.Notify(held.signals, os.Interrupt, syscall.SIGTERM)
+		go func(signals chan os.Signal) {
+			if _, ok := <-signals; !ok {
+				return
+			}
+			held.Lock()
+			for lock := range held.locks {
+				lock.file.Close()
+				os.Remove(lock.file.Name())
+			}
+			os.Exit(130)
+		}(held.signals)
+	}
+	held.locks[lock] = true
+}
+
+func untrackLock(lock *LockFile) {
+	held.Lock()
+	defer held.Unlock()
+	delete(held.locks, lock)
+	if len(held.locks) == 0 && held.signals != nil {
+		signal.Stop(held.signals)
+		close(held.signals)
+		held.signals = nil
+	}
+}
+
+// Lock takes the lock for path, waiting up to lockTimeout for another process
+// to release it before failing with a LockError
+func Lock(path string) (*LockFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(lockTimeout)
+	file, err := os.OpenFile(path+lockSuffix, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	for backoff := time.Millisecond; os.IsExist(err) && time.Now().Before(deadline); backoff = min(2*backoff, 50*time.Millisecond) {
+		time.Sleep(backoff)
+		file, err = os.OpenFile(path+lockSuffix, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	}
+	if os.IsExist(err) {
+		return nil, &LockError{Path: path + lockSuffix}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+	lock := &LockFile{path: path, file: file}
+	trackLock(lock)
+	return lock, nil
+}
+
+func (l *LockFile) Write(data []byte) error {
+	if _, err := l.file.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", l.file.Name(), err)
+	}
+	return nil
+}
+
+// Commit flushes the new content to disk and renames it over the locked file
+func (l *LockFile) Commit() error {
+	defer untrackLock(l)
+	if err := l.file.Sync(); err != nil {
+		l.file.Close()
+		os.Remove(l.file.Name())
+		return fmt.Errorf("failed to write %s: %w", l.file.Name(), err)
+	}
+	if err := l.file.Close(); err != nil {
+		os.Remove(l.file.Name())
+		return fmt.Errorf("failed to write %s: %w", l.file.Name(), err)
+	}
+	if err := os.Rename(l.file.Name(), l.path); err != nil {
+		os.Remove(l.file.Name())
+		return fmt.Errorf("failed to replace %s: %w", l.path, err)
+	}
+	return nil
+}
+
+// Rollback releases the lock and leaves the locked file as it was
+func (l *LockFile) Rollback() {
+	defer untrackLock(l)
+	l.file.Close()
+	os.Remove(l.file.Name())
+}
+
+// WriteFileAtomic replaces path with data under its lock
+func WriteFileAtomic(path string, data []byte) error {
+	lock, err := Lock(path)
+	if err != nil {
+		return err
+	}
+	if err := lock.Write(data); err != nil {
+		lock.Rollback()
+		return err
+	}
+	return lock.Commit()
+}
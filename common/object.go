@@ -10,39 +10,38 @@ import (
 	"path/filepath"
 )
 
-func WriteObject(repoRoot string,content []byte, fileType string, filePath string) (string, error) {
+func HashObject(content []byte, fileType string) string {
 	header := fmt.Sprintf("%s %d\x00", fileType, len(content))
-	fullData := append([]byte(header), content...)
+	hash := sha1.Sum(append([]byte(header), content...))
+	return fmt.Sprintf("%x", hash)
+}
 
-	hash := sha1.Sum(fullData)
-	stringHash := fmt.Sprintf("%x", hash)
-	objFolder := filepath.Join(repoRoot , RootDir , ObjectDir , stringHash[:2])
-	objFile := filepath.Join(objFolder , stringHash[2:])
-	if _, err := os.Stat(objFile); err == nil { // if same object already exists dont add it 
+func WriteObject(repoRoot string, content []byte, fileType string, filePath string) (string, error) {
+	stringHash := HashObject(content, fileType)
+	if ObjectExists(repoRoot, stringHash) { // if same object already exists dont add it
 		return stringHash, nil
 	}
-	if err := os.MkdirAll(objFolder, 0744); err != nil {
-		return "", err
-	}
-	var bytes bytes.Buffer
-	writer := zlib.NewWriter(&bytes)
-	if _, err := writer.Write(fullData); err != nil {
-		return "", fmt.Errorf("failed to compress object data: %w", err) 
-	}
-	if err := writer.Close(); err != nil {
-		return "", fmt.Errorf("failed to close zlib writer: %w", err)
-	}
-	if err := os.WriteFile(objFile, bytes.Bytes(), 0644); err != nil {
+	stringHash, written, err := WriteObjectFromReader(repoRoot, bytes.NewReader(content), int64(len(content)), fileType)
+	if err != nil {
 		return "", err
 	}
-	if fileType == BlobFile && filePath != "" {
+	if written && fileType == BlobFile && filePath != "" {
 		fmt.Print(fmt.Sprintln("adding file", filePath))
 	}
 	return stringHash, nil
 }
 
 func ReadObject(repoRoot string, objectSha string) ([]byte, error) {
-	folderDir := filepath.Join(repoRoot, RootDir, ObjectDir, objectSha[:2])
+	_, content, err := ReadObjectWithType(repoRoot, objectSha)
+	return content, err
+}
+
+// ReadRawObject returns the decompressed object including its "<type> <size>\x00" header
+func ReadRawObject(repoRoot string, objectSha string) ([]byte, error) {
+	if len(objectSha) < 3 {
+		return nil, fmt.Errorf("invalid object name %q", objectSha)
+	}
+	folderDir := filepath.Join(CommonDir(repoRoot), ObjectDir, objectSha[:2])
 	fileDir := filepath.Join(folderDir, objectSha[2:])
 	content, err := os.ReadFile(fileDir)
 	if err != nil {
@@ -52,15 +51,72 @@ func ReadObject(repoRoot string, objectSha string) ([]byte, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to start reader: %w", err)
 	}
+	defer zr.Close()
 	decompressedData, err := io.ReadAll(zr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read decompressed data: %w", err)
 	}
-	defer zr.Close()
+	return decompressedData, nil
+}
+
+// ParseObjectHeader splits a raw object into its type, declared size and content
+func ParseObjectHeader(raw []byte) (string, int, []byte, error) {
 	// Strip the header (format: "commit <size>\x00" or "tree <size>\x00" etc.)
-	nullIndex := bytes.IndexByte(decompressedData, '\x00')
+	nullIndex := bytes.IndexByte(raw, '\x00')
 	if nullIndex == -1 {
-		return nil, fmt.Errorf("invalid object format: missing null byte")
+		return "", 0, nil, fmt.Errorf("invalid object format: missing null byte")
+	}
+	header := string(raw[:nullIndex])
+	var objType string
+	var size int
+	if n, err := fmt.Sscanf(header, "%s %d", &objType, &size); err != nil || n != 2 {
+		return "", 0, nil, fmt.Errorf("invalid object header %q", header)
 	}
-	return decompressedData[nullIndex+1:], nil // +1 because we want to skip the \x00
-}
\ No newline at end of file
+	return objType, size, raw[nullIndex+1:], nil // +1 because we want to skip the \x00
+}
+
+// ReadObjectWithType is like ReadObject but also returns the type from the object header
+func ReadObjectWithType(repoRoot string, objectSha string) (string, []byte, error) {
+	raw, err := ReadRawObject(repoRoot, objectSha)
+	if err != nil {
+		return "", nil, err
+	}
+	objType, _, content, err := ParseObjectHeader(raw)
+	if err != nil {
+		return "", nil, err
+	}
+	return objType, content, nil
+}
+
+func ObjectExists(repoRoot string, objectSha string) bool {
+	if len(objectSha) < 3 {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(CommonDir(repoRoot), ObjectDir, objectSha[:2], objectSha[2:]))
+	return err == nil
+}
+
+// ListObjects returns the sha of every loose object in the object store
+func ListObjects(repoRoot string) ([]string, error) {
+	objectsDir := filepath.Join(CommonDir(repoRoot), ObjectDir)
+	folders, err := os.ReadDir(objectsDir)
+	if err != nil {
+		return nil, err
+	}
+	var shas []string
+	for _, folder := range folders {
+		if !folder.IsDir() || len(folder.Name()) != 2 {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(objectsDir, folder.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if !file.IsDir() && len(file.Name()) == 38 {
+				shas = append(shas, folder.Name()+file.Name())
+			}
+		}
+	}
+	return shas, nil
+}
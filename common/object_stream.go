@@ -0,0 +1,160 @@
+package common
+
+import (
+	"bufio"
+	"compress/zlib"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// the streaming versions of WriteObject and ReadObject never hold more than
+// a buffer of the object in memory, so they work for files of any size
+
+// HashObjectFromReader returns the sha of size bytes read from r as an
+// object of fileType without writing anything
+func HashObjectFromReader(r io.Reader, size int64, fileType string) (string, error) {
+	hash := sha1.New()
+	fmt.Fprintf(hash, "%s %d\x00", fileType, size)
+	if err := copyExactly(hash, r, size); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+// copyExactly copies size bytes and fails if r has more or fewer, which for
+// a file means it changed while it was read
+func copyExactly(w io.Writer, r io.Reader, size int64) error {
+	copied, err := io.Copy(w, io.LimitReader(r, size))
+	if err != nil {
+		return err
+	}
+	if copied != size {
+		return fmt.Errorf("expected %d bytes but read %d", size, copied)
+	}
+	if n, _ := r.Read(make([]byte, 1)); n != 0 {
+		return fmt.Errorf("expected %d bytes but there is more", size)
+	}
+	return nil
+}
+
+// WriteObjectFromReader stores size bytes read from r as an object of
+// fileType. The content is hashed and compressed in one pass into a
+// temporary file that is renamed into place once the sha is known. written
+// is false when the object was already in the store.
+func WriteObjectFromReader(repoRoot string, r io.Reader, size int64, fileType string) (sha string, written bool, err error) {
+	objectsDir := filepath.Join(CommonDir(repoRoot), ObjectDir)
+	if err := os.MkdirAll(objectsDir, 0755); err != nil {
+		return "", false, err
+	}
+	tmpFile, err := os.CreateTemp(objectsDir, "tmp_obj_")
+	if err != nil {
+		return "", false, err
+	}
+	defer func() {
+		if tmpFile != nil {
+			tmpFile.Close()
+			os.Remove(tmpFile.Name())
+		}
+	}()
+
+	hash := sha1.New()
+	buffered := bufio.NewWriter(tmpFile)
+	compressor := zlib.NewWriter(buffered)
+	out := io.MultiWriter(hash, compressor)
+	fmt.Fprintf(out, "%s %d\x00", fileType, size)
+	if err := copyExactly(out, r, size); err != nil {
+		return "", false, fmt.Errorf("failed to write object: %w", err)
+	}
+	if err := compressor.Close(); err != nil {
+		return "", false, fmt.Errorf("failed to close zlib writer: %w", err)
+	}
+	if err := buffered.Flush(); err != nil {
+		return "", false, err
+	}
+	sha = fmt.Sprintf("%x", hash.Sum(nil))
+	if ObjectExists(repoRoot, sha) { // if same object already exists dont add it
+		return sha, false, nil
+	}
+	if err := tmpFile.Close(); err != nil {
+		return "", false, err
+	}
+	objFile := filepath.Join(objectsDir, sha[:2], sha[2:])
+	if err := os.MkdirAll(filepath.Dir(objFile), 0755); err != nil {
+		return "", false, err
+	}
+	if err := os.Chmod(tmpFile.Name(), 0644); err != nil {
+		return "", false, err
+	}
+	if err := os.Rename(tmpFile.Name(), objFile); err != nil {
+		return "", false, err
+	}
+	tmpFile = nil
+	return sha, true, nil
+}
+
+// ObjectReader streams the content of an object, Type and Size come from its header
+type ObjectReader struct {
+	Type      string
+	Size      int64
+	file      *os.File
+	zr        io.ReadCloser
+	content   io.Reader
+	remaining int64
+}
+
+// OpenObject opens an object for reading without loading it, the caller has to Close it
+func OpenObject(repoRoot string, objectSha string) (*ObjectReader, error) {
+	if len(objectSha) < 3 {
+		return nil, fmt.Errorf("invalid object name %q", objectSha)
+	}
+	file, err := os.Open(filepath.Join(CommonDir(repoRoot), ObjectDir, objectSha[:2], objectSha[2:]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object: %w", err)
+	}
+	zr, err := zlib.NewReader(bufio.NewReader(file))
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to start reader: %w", err)
+	}
+	content := bufio.NewReader(zr)
+	header, err := content.ReadString('\x00')
+	if err != nil {
+		zr.Close()
+		file.Close()
+		return nil, fmt.Errorf("invalid object format: missing null byte")
+	}
+	objType, sizeStr, found := strings.Cut(strings.TrimSuffix(header, "\x00"), " ")
+	size, err := strconv.ParseInt(sizeStr, 10, 64)
+	if !found || err != nil || size < 0 {
+		zr.Close()
+		file.Close()
+		return nil, fmt.Errorf("invalid object header %q", header)
+	}
+	return &ObjectReader{Type: objType, Size: size, file: file, zr: zr, content: content, remaining: size}, nil
+}
+
+// Read returns io.ErrUnexpectedEOF when the object is shorter than its header says
+func (o *ObjectReader) Read(p []byte) (int, error) {
+	if o.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > o.remaining {
+		p = p[:o.remaining]
+	}
+	n, err := o.content.Read(p)
+	o.remaining -= int64(n)
+	if err == io.EOF && o.remaining > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (o *ObjectReader) Close() error {
+	o.zr.Close()
+	return o.file.Close()
+}
@@ -0,0 +1,154 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// packed-refs holds one "<sha> <ref name>" line per ref so a repository with
+// thousands of branches and tags does not need a file for each of them. A
+// loose ref file always wins over the packed line of the same name.
+const packedRefsHeader = "# pack-refs with: sorted\n"
+
+func packedRefsPath(repoRoot string) string {
+	return filepath.Join(CommonDir(repoRoot), PackedRefs)
+}
+
+func parsePackedRefs(content string) (map[string]string, error) {
+	refs := make(map[string]string)
+	for _, line := range strings.Split(content, "\n") {
+		// ^<sha> lines are the peeled target of the tag above them, nothing here needs them
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "^") {
+			continue
+		}
+		sha, name, found := strings.Cut(line, " ")
+		if !found || len(sha) != len(ZeroSha) {
+			return nil, fmt.Errorf("invalid %s line %q", PackedRefs, line)
+		}
+		refs[name] = sha
+	}
+	return refs, nil
+}
+
+func formatPackedRefs(refs map[string]string) []byte {
+	names := make([]string, 0, len(refs))
+	for name := range refs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var content strings.Builder
+	content.WriteString(packedRefsHeader)
+	for _, name := range names {
+		fmt.Fprintf(&content, "%s %s\n", refs[name], name)
+	}
+	return []byte(content.String())
+}
+
+// ReadPackedRefs returns the refs in packed-refs, which is empty when there is no such file
+func ReadPackedRefs(repoRoot string) (map[string]string, error) {
+	content, err := os.ReadFile(packedRefsPath(repoRoot))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return make(map[string]string), nil
+		}
+		return nil, err
+	}
+	return parsePackedRefs(string(content))
+}
+
+// listLooseRefs returns every ref file under .minigit/refs, including the
+// empty file of a branch without commits
+func listLooseRefs(repoRoot string) (map[string]string, error) {
+	refs := make(map[string]string)
+	err := walkRefFiles(repoRoot, RefsDir, func(refName string, path string) error {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		refs[refName] = strings.TrimSpace(string(content))
+		return nil
+	})
+	return refs, err
+}
+
+// PackRefs moves the loose tags, and every other loose ref too when all is
+// set, into packed-refs and returns how many refs were packed
+func PackRefs(repoRoot string, all bool) (int, error) {
+	loose, err := listLooseRefs(repoRoot)
+	if err != nil {
+		return 0, err
+	}
+	var names []string
+	for name, sha := range loose {
+		if sha == "" || isWorktreeRef(name) { // an unborn branch has nothing to pack, bisect refs stay with their worktree
+			continue
+		}
+		if all || strings.HasPrefix(name, RefsDir+"/"+TagsDir+"/") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names) // a fixed lock order so two writers cannot deadlock
+	locks := make([]*LockFile, 0, len(names))
+	unlock := func() {
+		for _, lock := range locks {
+			lock.Rollback()
+		}
+	}
+	for _, name := range names {
+		lock, err := Lock(refPath(repoRoot, name))
+		if err != nil {
+			unlock()
+			return 0, err
+		}
+		locks = append(locks, lock)
+	}
+	packedLock, err := Lock(packedRefsPath(repoRoot))
+	if err != nil {
+		unlock()
+		return 0, err
+	}
+	packed, err := ReadPackedRefs(repoRoot)
+	if err != nil {
+		packedLock.Rollback()
+		unlock()
+		return 0, err
+	}
+	// the refs may have moved between listing and locking them
+	var packedNames []string
+	for _, name := range names {
+		content, err := os.ReadFile(refPath(repoRoot, name))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			packedLock.Rollback()
+			unlock()
+			return 0, err
+		}
+		if sha := strings.TrimSpace(string(content)); sha != "" {
+			packed[name] = sha
+			packedNames = append(packedNames, name)
+		}
+	}
+	if err := packedLock.Write(formatPackedRefs(packed)); err != nil {
+		packedLock.Rollback()
+		unlock()
+		return 0, err
+	}
+	if err := packedLock.Commit(); err != nil {
+		unlock()
+		return 0, err
+	}
+	// packed-refs now has the same values, so dropping the loose files changes nothing for readers
+	for _, name := range packedNames {
+		if err := os.Remove(refPath(repoRoot, name)); err != nil && !os.IsNotExist(err) {
+			unlock()
+			return 0, fmt.Errorf("failed to remove loose ref %s: %w", name, err)
+		}
+	}
+	unlock()
+	return len(packedNames), nil
+}
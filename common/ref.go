@@ -8,11 +8,13 @@ import (
 )
 
 func GetHeadRef(repoRoot string) (string, error) {
-	filePath := filepath.Join(repoRoot, RootDir, HEAD)
-	content, err := os.ReadFile(filePath)
+	content, err := os.ReadFile(refPath(repoRoot, HEAD))
 	if err != nil {
 		return "", err
 	}
+	if !strings.HasPrefix(string(content), "ref:") { // a detached HEAD holds the commit sha itself
+		return HEAD, nil
+	}
 	headRef := strings.TrimSpace(strings.TrimPrefix(string(content), "ref:"))
 	return headRef, nil
 }
@@ -22,25 +24,110 @@ func GetParentSha(repoRoot string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	filePath := filepath.Join(repoRoot, RootDir, headRef)
-	content, err := os.ReadFile(filePath)
+	sha, err := ReadRef(repoRoot, headRef)
 	if err != nil {
-		if os.IsNotExist(err){
+		if os.IsNotExist(err) {
 			return "", nil
 		}
 		return "", fmt.Errorf("failed to read ref %s: %w", headRef, err)
 	}
-	return strings.TrimSpace(string(content)), nil
+	return sha, nil
 }
 
-func UpdateHead(repoRoot string, newSha string) error {
-	headRef, err := GetHeadRef(repoRoot)
+// UpdateHead moves the branch HEAD is on, or HEAD itself when detached, from
+// oldSha to newSha. oldSha is the commit the new one was built on, "" for the
+// first commit, and the update fails if another process moved the branch since.
+func UpdateHead(repoRoot string, newSha string, oldSha string, reason string) error {
+	transaction := NewRefTransaction(repoRoot)
+	transaction.Update(HEAD, newSha, oldSha, reason)
+	return transaction.Commit()
+}
+
+// UpdateRef points refName at newSha and records the move in the reflog of
+// the ref, and of HEAD too when HEAD is currently on that ref
+func UpdateRef(repoRoot string, refName string, newSha string, reason string) error {
+	transaction := NewRefTransaction(repoRoot)
+	transaction.Set(refName, newSha, reason)
+	return transaction.Commit()
+}
+
+func refPath(repoRoot string, refName string) string {
+	return filepath.Join(refBaseDir(repoRoot, refName), filepath.FromSlash(refName))
+}
+
+// WriteRef points refName at sha without touching any reflog
+func WriteRef(repoRoot string, refName string, sha string) error {
+	if err := WriteFileAtomic(refPath(repoRoot, refName), []byte(sha+"\n")); err != nil {
+		return fmt.Errorf("failed to write ref %s: %w", refName, err)
+	}
+	return nil
+}
+
+// DeleteRef removes a ref, loose or packed, together with its reflog
+func DeleteRef(repoRoot string, refName string) error {
+	transaction := NewRefTransaction(repoRoot)
+	transaction.Delete(refName, "", "")
+	return transaction.Commit()
+}
+
+// SetHeadRef makes HEAD a symbolic ref to refName, logging the move in the HEAD reflog
+func SetHeadRef(repoRoot string, refName string, reason string) error {
+	oldSha, _ := GetParentSha(repoRoot)
+	if err := WriteFileAtomic(refPath(repoRoot, HEAD), []byte("ref: "+refName+"\n")); err != nil {
+		return fmt.Errorf("failed to update head: %w", err)
+	}
+	newSha, _ := ReadRef(repoRoot, refName)
+	if newSha == "" {
+		return nil
+	}
+	return AppendReflog(repoRoot, HEAD, oldSha, newSha, reason)
+}
+
+// SetHeadDetached points HEAD directly at a commit instead of a branch
+func SetHeadDetached(repoRoot string, sha string, reason string) error {
+	oldSha, _ := GetParentSha(repoRoot)
+	if err := WriteFileAtomic(refPath(repoRoot, HEAD), []byte(sha+"\n")); err != nil {
+		return fmt.Errorf("failed to update head: %w", err)
+	}
+	return AppendReflog(repoRoot, HEAD, oldSha, sha, reason)
+}
+
+// ReadRef returns the sha of a loose ref, falling back to packed-refs. A ref
+// that is in neither returns the not exist error of the loose file.
+func ReadRef(repoRoot string, refName string) (string, error) {
+	content, err := os.ReadFile(refPath(repoRoot, refName))
+	if err == nil {
+		return strings.TrimSpace(string(content)), nil
+	}
+	if !os.IsNotExist(err) || refName == HEAD {
+		return "", err
+	}
+	packed, packedErr := ReadPackedRefs(repoRoot)
+	if packedErr != nil {
+		return "", packedErr
+	}
+	if sha, found := packed[refName]; found {
+		return sha, nil
+	}
+	return "", err
+}
+
+// ListRefs returns every loose and packed ref mapped to the sha it points to
+func ListRefs(repoRoot string) (map[string]string, error) {
+	refs, err := ReadPackedRefs(repoRoot)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to list refs: %w", err)
 	}
-	filePath := filepath.Join(repoRoot, RootDir, headRef)
-	if err := os.WriteFile(filePath, []byte(newSha + "\n"), 0644); err != nil {
-		return fmt.Errorf("failed to update head ref %s: %w", headRef, err)
+	loose, err := listLooseRefs(repoRoot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list refs: %w", err)
 	}
-	return nil
+	for refName, sha := range loose {
+		if sha == "" { // a freshly initialized branch has no commit yet
+			delete(refs, refName)
+		} else {
+			refs[refName] = sha
+		}
+	}
+	return refs, nil
 }
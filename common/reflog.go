@@ -0,0 +1,144 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// a reflog line looks like
+//
+//	<old sha> <new sha> <name> <<email>> <unix seconds> <zone>\t<reason>
+type ReflogEntry struct {
+	OldSha    string
+	NewSha    string
+	Identity  string
+	Timestamp string
+	Reason    string
+}
+
+// Identity returns "Name <email>" from MINIGIT_AUTHOR_NAME and MINIGIT_AUTHOR_EMAIL,
+// falling back to the current os user
+func Identity() string {
+	name := os.Getenv("MINIGIT_AUTHOR_NAME")
+	email := os.Getenv("MINIGIT_AUTHOR_EMAIL")
+	if name == "" || email == "" {
+		username := "unknown"
+		if current, err := user.Current(); err == nil {
+			username = current.Username
+		}
+		hostname, _ := os.Hostname()
+		if name == "" {
+			name = username
+		}
+		if email == "" {
+			email = username + "@" + hostname
+		}
+	}
+	return fmt.Sprintf("%s <%s>", name, email)
+}
+
+func reflogPath(repoRoot string, refName string) string {
+	return filepath.Join(refBaseDir(repoRoot, refName), LogsDir, filepath.FromSlash(refName))
+}
+
+func (e ReflogEntry) String() string {
+	return fmt.Sprintf("%s %s %s %s\t%s\n", e.OldSha, e.NewSha, e.Identity, e.Timestamp, e.Reason)
+}
+
+// Time returns when the entry was recorded
+func (e ReflogEntry) Time() time.Time {
+	t, _ := ParseTimestamp(e.Timestamp)
+	return t
+}
+
+func AppendReflog(repoRoot string, refName string, oldSha string, newSha string, reason string) error {
+	if oldSha == "" {
+		oldSha = ZeroSha
+	}
+	entry := ReflogEntry{
+		OldSha:    oldSha,
+		NewSha:    newSha,
+		Identity:  Identity(),
+		Timestamp: Timestamp(time.Now()),
+		Reason:    strings.ReplaceAll(reason, "\n", " "),
+	}
+	logPath := reflogPath(repoRoot, refName)
+	if err := os.MkdirAll(filepath.Dir(logPath), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open reflog for %s: %w", refName, err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(entry.String()); err != nil {
+		return fmt.Errorf("failed to write reflog for %s: %w", refName, err)
+	}
+	return nil
+}
+
+func parseReflogLine(line string) (ReflogEntry, error) {
+	fields, reason, _ := strings.Cut(line, "\t")
+	parts := strings.SplitN(fields, " ", 3)
+	if len(parts) != 3 {
+		return ReflogEntry{}, fmt.Errorf("invalid reflog line %q", line)
+	}
+	identityEnd := strings.LastIndexByte(parts[2], '>')
+	if identityEnd == -1 {
+		return ReflogEntry{}, fmt.Errorf("invalid reflog line %q", line)
+	}
+	return ReflogEntry{
+		OldSha:    parts[0],
+		NewSha:    parts[1],
+		Identity:  parts[2][:identityEnd+1],
+		Timestamp: strings.TrimSpace(parts[2][identityEnd+1:]),
+		Reason:    reason,
+	}, nil
+}
+
+// ReadReflog returns the reflog of a ref with the newest entry first, so
+// entry n is what ref@{n} means
+func ReadReflog(repoRoot string, refName string) ([]ReflogEntry, error) {
+	content, err := os.ReadFile(reflogPath(repoRoot, refName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var entries []ReflogEntry
+	for _, line := range strings.Split(strings.TrimRight(string(content), "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		entry, err := parseReflogLine(line)
+		if err != nil {
+			return nil, err
+		}
+		entries = append([]ReflogEntry{entry}, entries...)
+	}
+	return entries, nil
+}
+
+// WriteReflog replaces a reflog, entries are expected newest first like ReadReflog returns them
+func WriteReflog(repoRoot string, refName string, entries []ReflogEntry) error {
+	var content strings.Builder
+	for i := len(entries) - 1; i >= 0; i-- {
+		content.WriteString(entries[i].String())
+	}
+	return WriteFileAtomic(reflogPath(repoRoot, refName), []byte(content.String()))
+}
+
+// ListReflogs returns the name of every ref that has a reflog
+func ListReflogs(repoRoot string) ([]string, error) {
+	var refNames []string
+	err := walkRefFiles(repoRoot, LogsDir, func(refName string, path string) error {
+		refNames = append(refNames, refName)
+		return nil
+	})
+	return refNames, err
+}
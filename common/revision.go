@@ -0,0 +1,234 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var fullSha = regexp.MustCompile(`^[0-9a-f]{40}$`)
+var shortSha = regexp.MustCompile(`^[0-9a-f]{4,39}$`)
+
+// ResolveRevision turns a revision such as HEAD, a branch name, a (short) sha
+// or any of those followed by ~n / ^n / ^{type} into a full object sha. A tag
+// name gives the tag object itself, callers that need a commit peel it.
+func ResolveRevision(repoRoot string, rev string) (string, error) {
+	base, suffix := rev, ""
+	if i := strings.IndexAny(rev, "~^"); i != -1 {
+		base, suffix = rev[:i], rev[i:]
+	}
+	sha, err := resolveBase(repoRoot, base)
+	if err != nil {
+		return "", err
+	}
+	for suffix != "" {
+		op := suffix[0]
+		suffix = suffix[1:]
+		if op == '^' && strings.HasPrefix(suffix, "{") {
+			end := strings.IndexByte(suffix, '}')
+			if end == -1 {
+				return "", fmt.Errorf("invalid revision %q", rev)
+			}
+			objType := suffix[1:end]
+			if objType != "" && objType != CommitFile && objType != TreeFile && objType != BlobFile && objType != TagFile {
+				return "", fmt.Errorf("invalid object type %q in %s", objType, rev)
+			}
+			if sha, err = PeelTo(repoRoot, sha, objType); err != nil {
+				return "", fmt.Errorf("%s: %w", rev, err)
+			}
+			suffix = suffix[end+1:]
+			continue
+		}
+		digits := 0
+		for digits < len(suffix) && suffix[digits] >= '0' && suffix[digits] <= '9' {
+			digits++
+		}
+		n := 1
+		if digits > 0 {
+			n, _ = strconv.Atoi(suffix[:digits])
+			suffix = suffix[digits:]
+		}
+		if op == '~' {
+			for ; n > 0; n-- {
+				if sha, err = nthParent(repoRoot, sha, 1); err != nil {
+					return "", fmt.Errorf("%s: %w", rev, err)
+				}
+			}
+		} else if op == '^' {
+			if n == 0 {
+				continue
+			}
+			if sha, err = nthParent(repoRoot, sha, n); err != nil {
+				return "", fmt.Errorf("%s: %w", rev, err)
+			}
+		} else {
+			return "", fmt.Errorf("invalid revision %q", rev)
+		}
+	}
+	return sha, nil
+}
+
+func nthParent(repoRoot string, sha string, n int) (string, error) {
+	commitSha, err := PeelTo(repoRoot, sha, CommitFile)
+	if err != nil {
+		return "", err
+	}
+	commit, err := ReadCommit(repoRoot, commitSha)
+	if err != nil {
+		return "", err
+	}
+	if n > len(commit.Parents) {
+		return "", fmt.Errorf("commit %s has no parent %d", commitSha[:7], n)
+	}
+	return commit.Parents[n-1], nil
+}
+
+func resolveBase(repoRoot string, name string) (string, error) {
+	if name == "HEAD" || name == "@" {
+		sha, err := GetParentSha(repoRoot)
+		if err != nil {
+			return "", err
+		}
+		if sha == "" {
+			return "", errors.New("HEAD does not point to a commit yet")
+		}
+		return sha, nil
+	}
+	if i := strings.Index(name, "@{"); i != -1 && strings.HasSuffix(name, "}") {
+		return resolveReflogEntry(repoRoot, name[:i], name[i+2:len(name)-1])
+	}
+	for _, refName := range refCandidates(name) {
+		sha, err := ReadRef(repoRoot, refName)
+		if err == nil && sha != "" {
+			return sha, nil
+		}
+	}
+	if fullSha.MatchString(name) {
+		if !ObjectExists(repoRoot, name) {
+			return "", fmt.Errorf("object %s not found", name)
+		}
+		return name, nil
+	}
+	if shortSha.MatchString(name) {
+		return expandShortSha(repoRoot, name)
+	}
+	return "", fmt.Errorf("unknown revision %q", name)
+}
+
+func refCandidates(name string) []string {
+	if strings.HasPrefix(name, "refs/") {
+		return []string{name}
+	}
+	return []string{"refs/" + name, "refs/heads/" + name, "refs/tags/" + name, "refs/remotes/" + name}
+}
+
+// ExpandRefName turns a short name like "master" into the full name of an existing ref
+func ExpandRefName(repoRoot string, name string) (string, error) {
+	if name == HEAD {
+		return HEAD, nil
+	}
+	for _, refName := range refCandidates(name) {
+		if _, err := ReadRef(repoRoot, refName); err == nil {
+			return refName, nil
+		}
+	}
+	return "", fmt.Errorf("unknown ref %q", name)
+}
+
+// resolveReflogEntry handles ref@{n}, an empty ref meaning the current branch
+func resolveReflogEntry(repoRoot string, name string, nth string) (string, error) {
+	n, err := strconv.Atoi(nth)
+	if err != nil || n < 0 {
+		return "", fmt.Errorf("invalid reflog selector %s@{%s}", name, nth)
+	}
+	var refName string
+	if name == "" {
+		refName, err = GetHeadRef(repoRoot)
+	} else {
+		refName, err = ExpandRefName(repoRoot, name)
+	}
+	if err != nil {
+		return "", err
+	}
+	entries, err := ReadReflog(repoRoot, refName)
+	if err != nil {
+		return "", err
+	}
+	if n >= len(entries) {
+		return "", fmt.Errorf("log for %s only has %d entries", refName, len(entries))
+	}
+	return entries[n].NewSha, nil
+}
+
+func expandShortSha(repoRoot string, prefix string) (string, error) {
+	entries, err := os.ReadDir(filepath.Join(CommonDir(repoRoot), ObjectDir, prefix[:2]))
+	if err != nil {
+		return "", fmt.Errorf("unknown revision %q", prefix)
+	}
+	var matches []string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), prefix[2:]) {
+			matches = append(matches, prefix[:2]+entry.Name())
+		}
+	}
+	if len(matches) == 0 {
+		return "", fmt.Errorf("unknown revision %q", prefix)
+	}
+	if len(matches) > 1 {
+		return "", fmt.Errorf("short sha %s is ambiguous", prefix)
+	}
+	return matches[0], nil
+}
+
+// PeelTo follows sha to an object of objType the way rev^{type} does: tags
+// are followed to what they tag, and a commit gives its tree when a tree is
+// wanted. An empty objType follows tags to the first object that is not one.
+func PeelTo(repoRoot string, sha string, objType string) (string, error) {
+	for {
+		current, data, err := ReadObjectWithType(repoRoot, sha)
+		if err != nil {
+			return "", err
+		}
+		if current == objType || (objType == "" && current != TagFile) {
+			return sha, nil
+		}
+		switch {
+		case current == TagFile:
+			tag, err := ParseTag(data)
+			if err != nil {
+				return "", err
+			}
+			sha = tag.Object
+		case current == CommitFile && objType == TreeFile:
+			commit, err := ParseCommit(data)
+			if err != nil {
+				return "", err
+			}
+			sha = commit.Tree
+		default:
+			return "", fmt.Errorf("object %s is a %s, not a %s", sha, current, objType)
+		}
+	}
+}
+
+// ResolveCommit resolves a revision that has to name a commit, a tag gives the commit it tags
+func ResolveCommit(repoRoot string, rev string) (string, error) {
+	sha, err := ResolveRevision(repoRoot, rev)
+	if err != nil {
+		return "", err
+	}
+	return PeelTo(repoRoot, sha, CommitFile)
+}
+
+// ResolveTree resolves a revision and peels it to a tree sha if it names a commit or tag
+func ResolveTree(repoRoot string, rev string) (string, error) {
+	sha, err := ResolveRevision(repoRoot, rev)
+	if err != nil {
+		return "", err
+	}
+	return PeelTo(repoRoot, sha, TreeFile)
+}
@@ -0,0 +1,300 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// signatures use the SSHSIG format of ssh-keygen -Y sign with ed25519 keys,
+// so ssh-keygen -Y verify can check them too
+
+const (
+	SignatureBegin     = "-----BEGIN SSH SIGNATURE-----"
+	SignatureEnd       = "-----END SSH SIGNATURE-----"
+	SignatureNamespace = "git"
+	sshEd25519         = "ssh-ed25519"
+	sshSigMagic        = "SSHSIG"
+	openSSHKeyMagic    = "openssh-key-v1\x00"
+)
+
+// sshString encodes data the way the ssh wire format does: a uint32 length then the bytes
+func sshString(data []byte) []byte {
+	out := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	return append(out, data...)
+}
+
+// sshReader reads the fields of the ssh wire format one at a time
+type sshReader struct {
+	data []byte
+	err  error
+}
+
+func (r *sshReader) uint32() uint32 {
+	if r.err != nil || len(r.data) < 4 {
+		r.err = errors.New("truncated data")
+		return 0
+	}
+	n := binary.BigEndian.Uint32(r.data)
+	r.data = r.data[4:]
+	return n
+}
+
+func (r *sshReader) string() []byte {
+	n := r.uint32()
+	if r.err != nil || uint32(len(r.data)) < n {
+		r.err = errors.New("truncated data")
+		return nil
+	}
+	field := r.data[:n]
+	r.data = r.data[n:]
+	return field
+}
+
+// MarshalPublicKey returns the ssh wire encoding of an ed25519 public key
+func MarshalPublicKey(key ed25519.PublicKey) []byte {
+	return append(sshString([]byte(sshEd25519)), sshString(key)...)
+}
+
+func parsePublicKey(wire []byte) (ed25519.PublicKey, error) {
+	r := &sshReader{data: wire}
+	keyType, key := r.string(), r.string()
+	if r.err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", r.err)
+	}
+	if string(keyType) != sshEd25519 || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("unsupported key type %q, only %s keys are supported", keyType, sshEd25519)
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// Fingerprint returns the SHA256 fingerprint ssh-keygen -l shows for a key
+func Fingerprint(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(MarshalPublicKey(key))
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// LoadSigningKey reads an unencrypted ed25519 private key, either in OpenSSH
+// format as written by ssh-keygen or as PKCS#8 PEM as written by openssl
+func LoadSigningKey(path string) (ed25519.PrivateKey, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not a PEM encoded private key", path)
+	}
+	switch block.Type {
+	case "OPENSSH PRIVATE KEY":
+		return parseOpenSSHPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid private key in %s: %w", path, err)
+		}
+		if edKey, ok := key.(ed25519.PrivateKey); ok {
+			return edKey, nil
+		}
+		return nil, fmt.Errorf("%s is not an ed25519 key", path)
+	}
+	return nil, fmt.Errorf("unsupported private key type %q in %s", block.Type, path)
+}
+
+func parseOpenSSHPrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	if !bytes.HasPrefix(data, []byte(openSSHKeyMagic)) {
+		return nil, errors.New("invalid OpenSSH private key")
+	}
+	r := &sshReader{data: data[len(openSSHKeyMagic):]}
+	cipher := r.string()
+	r.string() // kdf name
+	r.string() // kdf options
+	if r.uint32() != 1 {
+		return nil, errors.New("OpenSSH private key files with several keys are not supported")
+	}
+	r.string() // public key
+	private := &sshReader{data: r.string()}
+	if r.err != nil {
+		return nil, fmt.Errorf("invalid OpenSSH private key: %w", r.err)
+	}
+	if string(cipher) != "none" {
+		return nil, errors.New("encrypted private keys are not supported, remove the passphrase or use a separate signing key")
+	}
+	if private.uint32() != private.uint32() {
+		return nil, errors.New("invalid OpenSSH private key: check bytes do not match")
+	}
+	keyType := private.string()
+	private.string() // public key
+	key := private.string()
+	if private.err != nil {
+		return nil, fmt.Errorf("invalid OpenSSH private key: %w", private.err)
+	}
+	if string(keyType) != sshEd25519 || len(key) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("unsupported key type %q, only %s keys are supported", keyType, sshEd25519)
+	}
+	return ed25519.PrivateKey(key), nil
+}
+
+// signedData is what the signature bytes are computed over in SSHSIG
+func signedData(namespace string, hashAlgorithm string, digest []byte) []byte {
+	data := []byte(sshSigMagic)
+	data = append(data, sshString([]byte(namespace))...)
+	data = append(data, sshString(nil)...) // reserved
+	data = append(data, sshString([]byte(hashAlgorithm))...)
+	return append(data, sshString(digest)...)
+}
+
+// Sign signs payload and returns the armored signature
+func Sign(key ed25519.PrivateKey, payload []byte) string {
+	digest := sha512.Sum512(payload)
+	signature := ed25519.Sign(key, signedData(SignatureNamespace, "sha512", digest[:]))
+
+	blob := []byte(sshSigMagic)
+	blob = binary.BigEndian.AppendUint32(blob, 1) // version
+	blob = append(blob, sshString(MarshalPublicKey(key.Public().(ed25519.PublicKey)))...)
+	blob = append(blob, sshString([]byte(SignatureNamespace))...)
+	blob = append(blob, sshString(nil)...)
+	blob = append(blob, sshString([]byte("sha512"))...)
+	blob = append(blob, sshString(append(sshString([]byte(sshEd25519)), sshString(signature)...))...)
+
+	encoded := base64.StdEncoding.EncodeToString(blob)
+	var armored strings.Builder
+	armored.WriteString(SignatureBegin + "\n")
+	for len(encoded) > 70 {
+		armored.WriteString(encoded[:70] + "\n")
+		encoded = encoded[70:]
+	}
+	armored.WriteString(encoded + "\n" + SignatureEnd)
+	return armored.String()
+}
+
+// VerifySignature checks an armored signature over payload and returns the
+// key that made it. Whether that key is trusted is up to the allowed signers.
+func VerifySignature(armored string, payload []byte) (ed25519.PublicKey, error) {
+	body := strings.TrimSpace(armored)
+	body, ok := strings.CutPrefix(body, SignatureBegin)
+	if !ok {
+		return nil, errors.New("not an SSH signature")
+	}
+	body, ok = strings.CutSuffix(body, SignatureEnd)
+	if !ok {
+		return nil, errors.New("truncated SSH signature")
+	}
+	blob, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(body), ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SSH signature: %w", err)
+	}
+	if !bytes.HasPrefix(blob, []byte(sshSigMagic)) {
+		return nil, errors.New("invalid SSH signature")
+	}
+	r := &sshReader{data: blob[len(sshSigMagic):]}
+	version := r.uint32()
+	publicKey := r.string()
+	namespace := r.string()
+	r.string() // reserved
+	hashAlgorithm := r.string()
+	signatureField := &sshReader{data: r.string()}
+	signatureType, signature := signatureField.string(), signatureField.string()
+	if r.err != nil || signatureField.err != nil {
+		return nil, errors.New("truncated SSH signature")
+	}
+	if version != 1 {
+		return nil, fmt.Errorf("unsupported SSH signature version %d", version)
+	}
+	if string(namespace) != SignatureNamespace {
+		return nil, fmt.Errorf("signature is for namespace %q, not %q", namespace, SignatureNamespace)
+	}
+	key, err := parsePublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	if string(signatureType) != sshEd25519 {
+		return nil, fmt.Errorf("unsupported signature type %q", signatureType)
+	}
+	var digest []byte
+	switch string(hashAlgorithm) {
+	case "sha512":
+		sum := sha512.Sum512(payload)
+		digest = sum[:]
+	case "sha256":
+		sum := sha256.Sum256(payload)
+		digest = sum[:]
+	default:
+		return nil, fmt.Errorf("unsupported signature hash %q", hashAlgorithm)
+	}
+	if !ed25519.Verify(key, signedData(string(namespace), string(hashAlgorithm), digest), signature) {
+		return key, errors.New("signature does not match")
+	}
+	return key, nil
+}
+
+// AllowedSigner is one line of an allowed signers file:
+//
+//	<principal>[,<principal>...] [options] ssh-ed25519 <base64 key> [comment]
+//
+// the same format ssh-keygen -Y verify and git use
+type AllowedSigner struct {
+	Principals []string
+	Key        ed25519.PublicKey
+}
+
+// ReadAllowedSigners parses an allowed signers file, lines with other key
+// types are skipped since they could not have signed anything here
+func ReadAllowedSigners(path string) ([]AllowedSigner, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read allowed signers: %w", err)
+	}
+	defer file.Close()
+	var signers []AllowedSigner
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		for i := 1; i+1 < len(fields); i++ {
+			if fields[i] != sshEd25519 {
+				continue
+			}
+			wire, err := base64.StdEncoding.DecodeString(fields[i+1])
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: invalid key: %w", path, lineNumber, err)
+			}
+			key, err := parsePublicKey(wire)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, lineNumber, err)
+			}
+			signers = append(signers, AllowedSigner{Principals: strings.Split(fields[0], ","), Key: key})
+			break
+		}
+	}
+	return signers, scanner.Err()
+}
+
+// FindPrincipal returns the principal of key that matches email, a principal
+// of "*" matches anyone. Found reports whether the key is listed at all.
+func FindPrincipal(signers []AllowedSigner, key ed25519.PublicKey, email string) (principal string, found bool) {
+	for _, signer := range signers {
+		if !signer.Key.Equal(key) {
+			continue
+		}
+		found = true
+		for _, candidate := range signer.Principals {
+			if candidate == email || candidate == "*" {
+				return email, true
+			}
+		}
+	}
+	return "", found
+}
@@ -0,0 +1,132 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// annotated tag objects look like this:
+//
+//	object <sha>
+//	type <type of the tagged object>
+//	tag <name>
+//	tagger <name> <<email>> <unix seconds> <zone>
+//
+//	<message>
+//	<armored signature>   (signed tags only)
+type Tag struct {
+	Object    string
+	Type      string
+	Name      string
+	Tagger    string
+	Message   string
+	Signature string // armored SSH signature over Payload, empty when unsigned
+}
+
+func ParseTag(data []byte) (*Tag, error) {
+	tag := &Tag{}
+	headers, body, _ := strings.Cut(string(data), "\n\n")
+	for _, line := range strings.Split(headers, "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "object":
+			tag.Object = strings.TrimSpace(value)
+		case "type":
+			tag.Type = strings.TrimSpace(value)
+		case "tag":
+			tag.Name = value
+		case "tagger":
+			tag.Tagger = value
+		}
+	}
+	if tag.Object == "" || tag.Type == "" {
+		return nil, fmt.Errorf("invalid tag: missing object or type")
+	}
+	if i := tagSignatureStart(body); i != -1 {
+		body, tag.Signature = body[:i], body[i:]
+	}
+	tag.Message = strings.TrimSuffix(body, "\n")
+	return tag, nil
+}
+
+func (t *Tag) Bytes() []byte {
+	var content bytes.Buffer
+	fmt.Fprintf(&content, "object %s\ntype %s\ntag %s\n", t.Object, t.Type, t.Name)
+	if t.Tagger != "" {
+		fmt.Fprintf(&content, "tagger %s\n", t.Tagger)
+	}
+	fmt.Fprintf(&content, "\n%s\n", t.Message)
+	if t.Signature != "" {
+		fmt.Fprintf(&content, "%s\n", strings.TrimSuffix(t.Signature, "\n"))
+	}
+	return content.Bytes()
+}
+
+// tagSignatureStart finds the armored signature that ends a tag, -1 when there is none
+func tagSignatureStart(content string) int {
+	i := strings.LastIndex(content, SignatureBegin)
+	if i == -1 || (i > 0 && content[i-1] != '\n') {
+		return -1
+	}
+	return i
+}
+
+// Payload is what the signature of a new tag covers: the tag without its
+// signature. Stored tags are verified against SignedTagPayload.
+func (t *Tag) Payload() []byte {
+	unsigned := *t
+	unsigned.Signature = ""
+	return unsigned.Bytes()
+}
+
+// SignedTagPayload returns what the signature of a stored tag covers: its
+// bytes as stored up to the armored signature at the end, like git
+func SignedTagPayload(data []byte) []byte {
+	headerEnd := bytes.Index(data, []byte("\n\n"))
+	if headerEnd == -1 {
+		return data
+	}
+	if i := tagSignatureStart(string(data[headerEnd:])); i != -1 {
+		return data[:headerEnd+i]
+	}
+	return data
+}
+
+// TaggerName returns the "Name <email>" part of the tagger header
+func (t *Tag) TaggerName() string {
+	if i := strings.LastIndexByte(t.Tagger, '>'); i != -1 {
+		return t.Tagger[:i+1]
+	}
+	return t.Tagger
+}
+
+func ReadTag(repoRoot string, tagSha string) (*Tag, error) {
+	objType, data, err := ReadObjectWithType(repoRoot, tagSha)
+	if err != nil {
+		return nil, err
+	}
+	if objType != TagFile {
+		return nil, fmt.Errorf("object %s is a %s, not a tag", tagSha, objType)
+	}
+	return ParseTag(data)
+}
+
+// SetDefaults fills in the tagger of a new tag, it has to happen before the tag is signed
+func (t *Tag) SetDefaults() {
+	if t.Tagger == "" {
+		t.Tagger = Identity() + " " + Timestamp(time.Now())
+	}
+}
+
+func WriteTag(repoRoot string, tag *Tag) (string, error) {
+	tag.SetDefaults()
+	return WriteObject(repoRoot, tag.Bytes(), TagFile, "")
+}
+
+// PeelTag follows annotated tags until it reaches the object they point to,
+// any other object is returned as it is
+func PeelTag(repoRoot string, sha string) (string, error) {
+	return PeelTo(repoRoot, sha, "")
+}
@@ -0,0 +1,257 @@
+package common
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// RefUpdate is one ref move inside a RefTransaction
+type RefUpdate struct {
+	Name   string
+	NewSha string
+	// OldSha is only compared when CheckOld is set, "" or ZeroSha then means
+	// the ref must not exist yet
+	OldSha   string
+	CheckOld bool
+	Delete   bool
+	Reason   string
+}
+
+// RefConflictError is returned when a ref is not at the value an update expected
+type RefConflictError struct {
+	Name     string
+	Expected string
+	Actual   string
+}
+
+func (e *RefConflictError) Error() string {
+	switch {
+	case e.Expected == "":
+		return fmt.Sprintf("cannot lock ref '%s': reference already exists", e.Name)
+	case e.Actual == "":
+		return fmt.Sprintf("cannot lock ref '%s': unable to resolve reference '%s'", e.Name, e.Name)
+	}
+	return fmt.Sprintf("cannot lock ref '%s': is at %s but expected %s", e.Name, e.Actual, e.Expected)
+}
+
+// RefTransaction moves several refs at once: every ref is locked and checked
+// against its expected value before any of them is written, so either all
+// updates happen or none do
+type RefTransaction struct {
+	repoRoot string
+	updates  []RefUpdate
+}
+
+func NewRefTransaction(repoRoot string) *RefTransaction {
+	return &RefTransaction{repoRoot: repoRoot}
+}
+
+// Update moves name to newSha only if it currently points to oldSha
+func (t *RefTransaction) Update(name string, newSha string, oldSha string, reason string) {
+	t.updates = append(t.updates, RefUpdate{Name: name, NewSha: newSha, OldSha: oldSha, CheckOld: true, Reason: reason})
+}
+
+// Set moves name to newSha whatever it points to now
+func (t *RefTransaction) Set(name string, newSha string, reason string) {
+	t.updates = append(t.updates, RefUpdate{Name: name, NewSha: newSha, Reason: reason})
+}
+
+// Delete removes name and its reflog, checking it points to oldSha unless that is ""
+func (t *RefTransaction) Delete(name string, oldSha string, reason string) {
+	t.updates = append(t.updates, RefUpdate{Name: name, OldSha: oldSha, CheckOld: oldSha != "", Delete: true, Reason: reason})
+}
+
+// CheckRefName rejects names git would refuse and names that cannot be told
+// apart from revision syntax
+func CheckRefName(name string) error {
+	if name == HEAD {
+		return nil
+	}
+	if !strings.HasPrefix(name, RefsDir+"/") || strings.HasSuffix(name, "/") || strings.HasSuffix(name, lockSuffix) ||
+		strings.Contains(name, "..") || strings.Contains(name, "//") || strings.Contains(name, "/.") ||
+		strings.Contains(name, "@{") || strings.ContainsAny(name, " ~^:?*[\\\t\n") {
+		return fmt.Errorf("invalid ref name '%s'", name)
+	}
+	return nil
+}
+
+func isSha(s string) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil && len(s) == len(ZeroSha)
+}
+
+// prepare resolves a symbolic HEAD to its branch and validates every update
+func (t *RefTransaction) prepare() (string, error) {
+	headRef, err := GetHeadRef(t.repoRoot)
+	if err != nil {
+		return "", err
+	}
+	seen := make(map[string]bool)
+	for i := range t.updates {
+		update := &t.updates[i]
+		if update.Name == HEAD {
+			update.Name = headRef
+		}
+		if err := CheckRefName(update.Name); err != nil {
+			return "", err
+		}
+		if seen[update.Name] {
+			return "", fmt.Errorf("multiple updates for ref '%s' not allowed", update.Name)
+		}
+		seen[update.Name] = true
+		if update.Delete && update.Name == HEAD {
+			return "", fmt.Errorf("refusing to delete a detached HEAD")
+		}
+		if update.OldSha == ZeroSha {
+			update.OldSha = ""
+		}
+		if update.OldSha != "" && !isSha(update.OldSha) {
+			return "", fmt.Errorf("invalid old value %q for %s", update.OldSha, update.Name)
+		}
+		if update.Delete || update.NewSha == "" { // "" keeps a branch without commits, like init creates
+			continue
+		}
+		if !isSha(update.NewSha) {
+			return "", fmt.Errorf("invalid new value %q for %s", update.NewSha, update.Name)
+		}
+		if !ObjectExists(t.repoRoot, update.NewSha) {
+			return "", fmt.Errorf("trying to write ref '%s' with nonexistent object %s", update.Name, update.NewSha)
+		}
+	}
+	sort.Slice(t.updates, func(i, j int) bool { return t.updates[i].Name < t.updates[j].Name })
+	return headRef, nil
+}
+
+// Commit locks the refs in name order, checks the expected values under the
+// locks and then writes everything. packed-refs is only locked when a ref is
+// deleted, since loose files override it for every other update.
+func (t *RefTransaction) Commit() error {
+	headRef, err := t.prepare()
+	if err != nil {
+		return err
+	}
+	locks := make([]*LockFile, len(t.updates))
+	var packedLock *LockFile
+	unlock := func() {
+		for _, lock := range locks {
+			if lock != nil {
+				lock.Rollback()
+			}
+		}
+		if packedLock != nil {
+			packedLock.Rollback()
+		}
+	}
+	deletes := false
+	for i, update := range t.updates {
+		if locks[i], err = Lock(refPath(t.repoRoot, update.Name)); err != nil {
+			unlock()
+			return err
+		}
+		deletes = deletes || update.Delete
+	}
+	if deletes {
+		if packedLock, err = Lock(packedRefsPath(t.repoRoot)); err != nil {
+			unlock()
+			return err
+		}
+	}
+
+	oldShas := make([]string, len(t.updates))
+	for i, update := range t.updates {
+		oldShas[i], err = ReadRef(t.repoRoot, update.Name)
+		if err != nil && !os.IsNotExist(err) {
+			unlock()
+			return err
+		}
+		if update.CheckOld && oldShas[i] != update.OldSha {
+			unlock()
+			return &RefConflictError{Name: update.Name, Expected: update.OldSha, Actual: oldShas[i]}
+		}
+		if !update.Delete {
+			if err := locks[i].Write([]byte(update.NewSha + "\n")); err != nil {
+				unlock()
+				return err
+			}
+		}
+	}
+
+	if packedLock != nil {
+		if err := t.commitPackedDeletes(packedLock); err != nil {
+			packedLock = nil
+			unlock()
+			return err
+		}
+		packedLock = nil
+	}
+	for i, update := range t.updates {
+		lock := locks[i]
+		locks[i] = nil
+		if update.Delete {
+			err = os.Remove(refPath(t.repoRoot, update.Name))
+			lock.Rollback()
+			if err != nil && !os.IsNotExist(err) {
+				unlock()
+				return fmt.Errorf("failed to delete ref %s: %w", update.Name, err)
+			}
+			continue
+		}
+		if err := lock.Commit(); err != nil {
+			unlock()
+			return fmt.Errorf("failed to write ref %s: %w", update.Name, err)
+		}
+	}
+	return t.writeReflogs(headRef, oldShas)
+}
+
+// commitPackedDeletes drops the deleted refs from packed-refs, it must happen
+// before the loose files go or the packed value would show through
+func (t *RefTransaction) commitPackedDeletes(packedLock *LockFile) error {
+	packed, err := ReadPackedRefs(t.repoRoot)
+	if err != nil {
+		packedLock.Rollback()
+		return err
+	}
+	changed := false
+	for _, update := range t.updates {
+		if _, found := packed[update.Name]; found && update.Delete {
+			delete(packed, update.Name)
+			changed = true
+		}
+	}
+	if !changed {
+		packedLock.Rollback()
+		return nil
+	}
+	if err := packedLock.Write(formatPackedRefs(packed)); err != nil {
+		packedLock.Rollback()
+		return err
+	}
+	return packedLock.Commit()
+}
+
+func (t *RefTransaction) writeReflogs(headRef string, oldShas []string) error {
+	for i, update := range t.updates {
+		if update.Delete {
+			if err := os.Remove(reflogPath(t.repoRoot, update.Name)); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to delete reflog of %s: %w", update.Name, err)
+			}
+			continue
+		}
+		if update.NewSha == "" || oldShas[i] == update.NewSha {
+			continue
+		}
+		if err := AppendReflog(t.repoRoot, update.Name, oldShas[i], update.NewSha, update.Reason); err != nil {
+			return err
+		}
+		if update.Name == headRef && headRef != HEAD {
+			if err := AppendReflog(t.repoRoot, HEAD, oldShas[i], update.NewSha, update.Reason); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
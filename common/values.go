@@ -6,10 +6,20 @@ var (
 	RefsDir    = "refs"
 	HEAD       = "HEAD"
 	IndexFile  = "index.json"
+	ConfigFile = "config.json"
 	CommitFile = "commit"
 	TreeFile   = "tree"
 	BlobFile   = "blob"
+	TagFile    = "tag"
 	HeadDir    = "heads"
+	TagsDir    = "tags"
+	RemotesDir = "remotes"
+	LogsDir    = "logs"
+	HooksDir   = "hooks"
+	LFSDir     = "lfs"
+	PackedRefs = "packed-refs"
+	Worktrees  = "worktrees"
+	ZeroSha    = "0000000000000000000000000000000000000000"
 )
 
 type Index map[string]string
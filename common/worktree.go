@@ -0,0 +1,194 @@
+package common
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// a linked worktree has a .minigit file instead of a folder, holding
+//
+//	minigitdir: <main worktree>/.minigit/worktrees/<name>
+//
+// That folder keeps what belongs to one working tree: HEAD, the index, the
+// pseudo refs, refs/bisect, their reflogs and any rebase, cherry-pick or
+// bisect in progress. Objects, the other refs, config, hooks and large files
+// are shared through the main .minigit folder, which its commondir file names.
+const minigitLinkPrefix = "minigitdir: "
+
+const (
+	// CommonDirFile in a worktree folder points to the shared .minigit folder, relative to itself
+	CommonDirFile = "commondir"
+	// MinigitDirFile in a worktree folder holds the path of the .minigit file that links to it
+	MinigitDirFile = "minigitdir"
+)
+
+// Worktree is one working tree of a repository, Name is "" for the main one
+type Worktree struct {
+	Name       string
+	Path       string
+	MinigitDir string
+}
+
+// ReadMinigitLink returns the worktree folder a .minigit file points to
+func ReadMinigitLink(linkPath string) (string, error) {
+	content, err := os.ReadFile(linkPath)
+	if err != nil {
+		return "", err
+	}
+	target, ok := strings.CutPrefix(strings.TrimSpace(string(content)), minigitLinkPrefix)
+	if !ok || target == "" {
+		return "", fmt.Errorf("invalid %s file %s", RootDir, linkPath)
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(linkPath), target)
+	}
+	return filepath.Clean(target), nil
+}
+
+// WriteMinigitLink makes the .minigit file of a linked worktree point to its folder
+func WriteMinigitLink(linkPath string, minigitDir string) error {
+	return WriteFileAtomic(linkPath, []byte(minigitLinkPrefix+minigitDir+"\n"))
+}
+
+// MinigitDir returns the folder with the HEAD and index of the worktree at
+// repoRoot, which is its .minigit folder unless it is a linked worktree
+func MinigitDir(repoRoot string) string {
+	dotDir := filepath.Join(repoRoot, RootDir)
+	if info, err := os.Stat(dotDir); err == nil && !info.IsDir() {
+		if target, err := ReadMinigitLink(dotDir); err == nil {
+			return target
+		}
+	}
+	return dotDir
+}
+
+// CommonDir returns the .minigit folder every worktree of the repository shares
+func CommonDir(repoRoot string) string {
+	dir := MinigitDir(repoRoot)
+	content, err := os.ReadFile(filepath.Join(dir, CommonDirFile))
+	if err != nil {
+		return dir
+	}
+	commonDir := strings.TrimSpace(string(content))
+	if !filepath.IsAbs(commonDir) {
+		commonDir = filepath.Join(dir, commonDir)
+	}
+	return filepath.Clean(commonDir)
+}
+
+// isWorktreeRef reports whether a ref belongs to a single worktree: HEAD and
+// the other pseudo refs outside refs/, and the refs bisect keeps
+func isWorktreeRef(refName string) bool {
+	return !strings.Contains(refName, "/") || strings.HasPrefix(refName, RefsDir+"/bisect/")
+}
+
+// refBaseDir returns the folder refName lives in for the worktree at repoRoot
+func refBaseDir(repoRoot string, refName string) string {
+	if isWorktreeRef(refName) {
+		return MinigitDir(repoRoot)
+	}
+	return CommonDir(repoRoot)
+}
+
+// walkRefFiles calls fn for every file under sub (refs or logs) that the
+// worktree at repoRoot sees: the shared ones from the common folder and its
+// own from the worktree folder. refName is the name of the ref the file is for.
+func walkRefFiles(repoRoot string, sub string, fn func(refName string, path string) error) error {
+	walk := func(baseDir string, wanted func(refName string) bool) error {
+		dir := filepath.Join(baseDir, sub)
+		return filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return filepath.SkipDir
+				}
+				return err
+			}
+			if entry.IsDir() || strings.HasSuffix(path, lockSuffix) { // a lock is an update in progress, not a ref
+				return nil
+			}
+			relPath, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			refName := filepath.ToSlash(relPath)
+			if sub == RefsDir {
+				refName = RefsDir + "/" + refName
+			}
+			if !wanted(refName) {
+				return nil
+			}
+			return fn(refName, path)
+		})
+	}
+	ownDir, commonDir := MinigitDir(repoRoot), CommonDir(repoRoot)
+	if ownDir == commonDir {
+		return walk(commonDir, func(string) bool { return true })
+	}
+	// the common folder is the main worktree's too, its HEAD and bisect refs are not ours
+	if err := walk(commonDir, func(refName string) bool { return !isWorktreeRef(refName) }); err != nil {
+		return err
+	}
+	return walk(ownDir, isWorktreeRef)
+}
+
+// ListWorktrees returns the main worktree followed by the linked ones. Path
+// is "" for a linked worktree whose minigitdir file is missing.
+func ListWorktrees(repoRoot string) ([]Worktree, error) {
+	commonDir := CommonDir(repoRoot)
+	worktrees := []Worktree{{Path: filepath.Dir(commonDir), MinigitDir: commonDir}}
+	entries, err := os.ReadDir(filepath.Join(commonDir, Worktrees))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		worktree := Worktree{Name: entry.Name(), MinigitDir: filepath.Join(commonDir, Worktrees, entry.Name())}
+		if link, err := os.ReadFile(filepath.Join(worktree.MinigitDir, MinigitDirFile)); err == nil {
+			worktree.Path = filepath.Dir(strings.TrimSpace(string(link)))
+		}
+		worktrees = append(worktrees, worktree)
+	}
+	return worktrees, nil
+}
+
+// Head returns the ref HEAD of the worktree is on, "" when it is detached,
+// and the commit it points to, "" for a branch without commits
+func (w Worktree) Head(repoRoot string) (string, string, error) {
+	content, err := os.ReadFile(filepath.Join(w.MinigitDir, HEAD))
+	if err != nil {
+		return "", "", err
+	}
+	refName, ok := strings.CutPrefix(strings.TrimSpace(string(content)), "ref:")
+	if !ok {
+		return "", strings.TrimSpace(string(content)), nil
+	}
+	refName = strings.TrimSpace(refName)
+	sha, err := ReadRef(repoRoot, refName)
+	if err != nil && !os.IsNotExist(err) {
+		return "", "", err
+	}
+	return refName, sha, nil
+}
+
+// Prunable reports why a linked worktree is gone, "" while it still exists
+func (w Worktree) Prunable() string {
+	if w.Name == "" {
+		return ""
+	}
+	if w.Path == "" {
+		return MinigitDirFile + " file is missing"
+	}
+	target, err := ReadMinigitLink(filepath.Join(w.Path, RootDir))
+	if os.IsNotExist(err) {
+		return MinigitDirFile + " file points to non-existent location"
+	}
+	if err != nil || target != w.MinigitDir {
+		return MinigitDirFile + " file does not point back to it"
+	}
+	return ""
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"github.com/hanzala211/mini-git/commands"
+	"github.com/hanzala211/mini-git/common"
 	"github.com/spf13/cobra"
 )
 
@@ -68,9 +69,402 @@ var mergeCmd = &cobra.Command{
 	},
 }
 
+var hashObjectCmd = &cobra.Command{
+	Use:   "hash-object [file...]",
+	Short: "Compute the object id of a file",
+	Long:  "Compute the object id of a file or stdin and optionally write it to the object store",
+	Run: func(cmd *cobra.Command, args []string) {
+		commands.HashObjectCommand(cmd, args)
+	},
+}
+
+var catFileCmd = &cobra.Command{
+	Use:   "cat-file <object>",
+	Short: "Show the type, size or content of an object",
+	Long:  "Show the type (-t), size (-s) or pretty printed content (-p) of an object in the repository",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		commands.CatFileCommand(cmd, args)
+	},
+}
+
+var lsTreeCmd = &cobra.Command{
+	Use:   "ls-tree <tree-ish>",
+	Short: "List the contents of a tree object",
+	Long:  "List the contents of a tree object, or the tree of a commit",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		commands.LsTreeCommand(cmd, args)
+	},
+}
+
+var writeTreeCmd = &cobra.Command{
+	Use:   "write-tree",
+	Short: "Create a tree object from the index",
+	Long:  "Create a tree object from the current index and print its sha",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		commands.WriteTreeCommand(cmd, args)
+	},
+}
+
+var commitTreeCmd = &cobra.Command{
+	Use:   "commit-tree <tree>",
+	Short: "Create a commit object from a tree",
+	Long:  "Create a new commit object from a tree and optional parents and print its sha",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		commands.CommitTreeCommand(cmd, args)
+	},
+}
+
+var fsckCmd = &cobra.Command{
+	Use:   "fsck",
+	Short: "Verify the integrity of the object store",
+	Long:  "Rehash every object, check headers and links, and report missing, corrupt and dangling objects",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		commands.FsckCommand(cmd, args)
+	},
+}
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove unreachable objects",
+	Long:  "Remove loose objects that are not reachable from any ref, the index or a reflog",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		commands.PruneCommand(cmd, args)
+	},
+}
+
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Clean up unnecessary files in the repository",
+	Long:  "Clean up the repository by pruning unreachable objects older than the grace period",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		commands.GcCommand(cmd, args)
+	},
+}
+
+var reflogCmd = &cobra.Command{
+	Use:   "reflog [show|expire] [<ref>...]",
+	Short: "Show or expire reflog entries",
+	Long:  "Show the history of where HEAD or a branch has pointed, or expire old reflog entries",
+	Run: func(cmd *cobra.Command, args []string) {
+		commands.ReflogCommand(cmd, args)
+	},
+}
+
+var stashCmd = &cobra.Command{
+	Use:   "stash [push|pop|apply|list|drop|show] [stash@{n}]",
+	Short: "Stash away changes in the working directory",
+	Long:  "Save the staged and unstaged changes away on a stack and restore them later",
+	Run: func(cmd *cobra.Command, args []string) {
+		commands.StashCommand(cmd, args)
+	},
+}
+
+var cherryPickCmd = &cobra.Command{
+	Use:   "cherry-pick <commit>...",
+	Short: "Apply the changes of existing commits",
+	Long:  "Replay the changes introduced by one or more commits on top of the current branch",
+	Run: func(cmd *cobra.Command, args []string) {
+		commands.CherryPickCommand(cmd, args)
+	},
+}
+
+var revertCmd = &cobra.Command{
+	Use:   "revert <commit>...",
+	Short: "Revert existing commits",
+	Long:  "Create new commits that undo the changes introduced by existing commits",
+	Run: func(cmd *cobra.Command, args []string) {
+		commands.RevertCommand(cmd, args)
+	},
+}
+
+var rebaseCmd = &cobra.Command{
+	Use:   "rebase [-i] [--autosquash] <upstream> [--onto <newbase>]",
+	Short: "Reapply commits on top of another base",
+	Long:  "Replay the commits of the current branch that are not in upstream on top of a new base",
+	Run: func(cmd *cobra.Command, args []string) {
+		commands.RebaseCommand(cmd, args)
+	},
+}
+
+var blameCmd = &cobra.Command{
+	Use:   "blame <file> [<rev>]",
+	Short: "Show what commit last changed each line of a file",
+	Long:  "Annotate each line of a file with the commit, author and date that introduced it",
+	Args:  cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		commands.BlameCommand(cmd, args)
+	},
+}
+
+var bisectCmd = &cobra.Command{
+	Use:   "bisect [start|bad|good|skip|reset|log|run] [<args>]",
+	Short: "Use binary search to find the commit that introduced a bug",
+	Long:  "Check out commits between a known good and a known bad commit until the first bad one is found, optionally running a command to decide",
+	// flags after "bisect run" belong to the command being run
+	DisableFlagParsing: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		commands.BisectCommand(cmd, args)
+	},
+}
+
+var grepCmd = &cobra.Command{
+	Use:   "grep <pattern> [<rev>] [-- <path>...]",
+	Short: "Print lines matching a pattern",
+	Long:  "Search the tracked files in the working tree, the index (--cached) or any revision for a regular expression without checking anything out",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		commands.GrepCommand(cmd, args)
+	},
+}
+
+var archiveCmd = &cobra.Command{
+	Use:   "archive [--format=tar|tar.gz|zip] [--prefix=<dir>/] <rev> [<path>...]",
+	Short: "Create an archive of the files of a commit",
+	Long:  "Write the files of a commit or tree to a tar, tar.gz or zip archive straight from the object store, without a checkout",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		commands.ArchiveCommand(cmd, args)
+	},
+}
+
+var cloneCmd = &cobra.Command{
+	Use:   "clone <path|url> [<dir>]",
+	Short: "Clone a repository into a new directory",
+	Long:  "Copy the objects and branches of a local or served repository into a new directory, set it up as the origin remote and check out its current branch",
+	Args:  cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		commands.CloneCommand(cmd, args)
+	},
+}
+
+var remoteCmd = &cobra.Command{
+	Use:   "remote [list|add|remove] [<name>] [<url>]",
+	Short: "Manage the remotes of the repository",
+	Long:  "List, add or remove the remote repositories that fetch and push talk to",
+	Run: func(cmd *cobra.Command, args []string) {
+		commands.RemoteCommand(cmd, args)
+	},
+}
+
+var fetchCmd = &cobra.Command{
+	Use:   "fetch [<remote>]",
+	Short: "Download objects and branches from a remote",
+	Long:  "Copy the branches of a remote and the objects they need into refs/remotes/<remote>/*",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		commands.FetchCommand(cmd, args)
+	},
+}
+
+var pushCmd = &cobra.Command{
+	Use:   "push [<remote>] [<branch>|<src>:<dst>]",
+	Short: "Update a remote branch along with the objects it needs",
+	Long:  "Send the objects a branch needs to a remote and move the remote branch, refusing anything but a fast-forward unless --force is given",
+	Args:  cobra.MaximumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		commands.PushCommand(cmd, args)
+	},
+}
+
+var serveCmd = &cobra.Command{
+	Use:   "serve [<dir>]",
+	Short: "Serve repositories over HTTP",
+	Long:  "Serve the repository in dir, or every repository below it, over HTTP so it can be cloned, fetched from and pushed to with an http:// url",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		commands.ServeCommand(cmd, args)
+	},
+}
+
+var bundleCmd = &cobra.Command{
+	Use:   "bundle [create|verify] <file> [--all | <rev-range>...]",
+	Short: "Move history between repositories in a single file",
+	Long:  "Write the refs and objects of a range of history to a bundle file, check one, or give its path to clone or remote add to read from it",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		commands.BundleCommand(cmd, args)
+	},
+}
+
+var tagCmd = &cobra.Command{
+	Use:   "tag [-a | -s] [-m <message>] [-f] <name> [<rev>] | tag -d <name>...",
+	Short: "Create, list or delete tags",
+	Long:  "Without arguments list the tags, otherwise point a tag at a commit. -a or -m make an annotated tag object and -s signs it",
+	Run: func(cmd *cobra.Command, args []string) {
+		commands.TagCommand(cmd, args)
+	},
+}
+
+var logCmd = &cobra.Command{
+	Use:   "log [<rev>]",
+	Short: "Show the commit history",
+	Long:  "Show the commits reachable from a revision, newest first, optionally with the status of their signatures",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		commands.LogCommand(cmd, args)
+	},
+}
+
+var verifyCommitCmd = &cobra.Command{
+	Use:   "verify-commit <commit>...",
+	Short: "Check the signatures of commits",
+	Long:  "Check that each commit carries a valid signature from a key the allowed signers file trusts for its author, exiting non-zero otherwise",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		commands.VerifyCommitCommand(cmd, args)
+	},
+}
+
+var verifyTagCmd = &cobra.Command{
+	Use:   "verify-tag <tag>...",
+	Short: "Check the signatures of tags",
+	Long:  "Check that each annotated tag carries a valid signature from a key the allowed signers file trusts for its tagger, exiting non-zero otherwise",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		commands.VerifyTagCommand(cmd, args)
+	},
+}
+
+var updateRefCmd = &cobra.Command{
+	Use:   "update-ref <ref> <new> [<old>]",
+	Short: "Update a ref safely",
+	Long:  "Point a ref at a new value, only if it is still at <old> when that is given (an empty or zero <old> means it must not exist). -d deletes the ref and --stdin applies update, create and delete lines in one all-or-nothing transaction",
+	Run: func(cmd *cobra.Command, args []string) {
+		commands.UpdateRefCommand(cmd, args)
+	},
+}
+
+var forEachRefCmd = &cobra.Command{
+	Use:   "for-each-ref [<pattern>...]",
+	Short: "Show information about each ref",
+	Long:  "List the loose and packed refs matching the patterns, formatted with %(refname), %(refname:short), %(objectname), %(objectname:short), %(objecttype), %(subject), %(author), %(authordate) and %(HEAD) atoms",
+	Run: func(cmd *cobra.Command, args []string) {
+		commands.ForEachRefCommand(cmd, args)
+	},
+}
+
+var packRefsCmd = &cobra.Command{
+	Use:   "pack-refs",
+	Short: "Pack refs into a single file",
+	Long:  "Move the loose tags, or every ref with --all, into .minigit/packed-refs so a repository with many refs needs fewer files",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		commands.PackRefsCommand(cmd, args)
+	},
+}
+
+var lfsCmd = &cobra.Command{
+	Use:   "lfs <track|untrack|ls-files|fetch|prune> [<args>...]",
+	Short: "Store large files outside the object store",
+	Long:  "Files matching the patterns in .minigitattributes are committed as small pointers while their content lives in .minigit/lfs. track and untrack edit the patterns, ls-files lists the large files in the index, fetch copies missing content from a local remote and prune deletes content nothing points to",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		commands.LFSCommand(cmd, args)
+	},
+}
+
+var worktreeCmd = &cobra.Command{
+	Use:   "worktree <add|list|remove|prune> [<args>...]",
+	Short: "Manage several working trees of one repository",
+	Long:  "add <path> [<commit-ish>] checks a branch or commit out in a new working directory with its own HEAD and index that shares the objects and refs of this repository. A branch can only be checked out in one worktree at a time. list shows every worktree, remove deletes one and prune forgets those whose directory was deleted",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		commands.WorktreeCommand(cmd, args)
+	},
+}
+
 func main() {
 
+	addCmd.Flags().IntP("jobs", "j", 0, "number of files hashed at once (default: number of CPUs)")
+	addCmd.Flags().Bool("progress", false, "show progress on stderr (default: when stderr is a terminal)")
 	commitCmd.Flags().String("m", "", "message for the commit (required)")
+	commitCmd.Flags().BoolP("no-verify", "n", false, "skip the pre-commit and commit-msg hooks")
+	commitCmd.Flags().String("fixup", "", "make a \"fixup!\" commit for the given commit, see rebase --autosquash")
+	commitCmd.Flags().BoolP("gpg-sign", "S", false, "sign the commit with signing.key from the config")
+	commitCmd.Flags().Bool("no-gpg-sign", false, "do not sign the commit even if signing.sign is set")
+	hashObjectCmd.Flags().BoolP("write", "w", false, "write the object into the object store")
+	hashObjectCmd.Flags().Bool("stdin", false, "read the content from stdin")
+	hashObjectCmd.Flags().StringP("type", "t", common.BlobFile, "type of the object")
+	catFileCmd.Flags().BoolP("type", "t", false, "show the object type")
+	catFileCmd.Flags().BoolP("size", "s", false, "show the object size")
+	catFileCmd.Flags().BoolP("pretty", "p", false, "pretty print the object content")
+	lsTreeCmd.Flags().BoolP("recursive", "r", false, "recurse into sub-trees")
+	commitTreeCmd.Flags().StringArrayP("parent", "p", nil, "parent commit (can be repeated)")
+	commitTreeCmd.Flags().StringP("message", "m", "", "commit message (read from stdin when omitted)")
+	commitTreeCmd.Flags().BoolP("gpg-sign", "S", false, "sign the commit with signing.key from the config")
+	commitTreeCmd.Flags().Bool("no-gpg-sign", false, "do not sign the commit even if signing.sign is set")
+	fsckCmd.Flags().Bool("no-dangling", false, "do not report dangling objects")
+	pruneCmd.Flags().String("expire", "now", "only prune objects older than this (e.g. 2.weeks.ago, 36h, now)")
+	pruneCmd.Flags().BoolP("dry-run", "n", false, "only show what would be pruned")
+	gcCmd.Flags().String("prune", commands.DefaultPruneExpiry, "prune unreachable objects older than this")
+	gcCmd.Flags().BoolP("dry-run", "n", false, "only show what would be pruned")
+	reflogCmd.Flags().String("expire", commands.DefaultReflogExpiry, "expire entries older than this")
+	reflogCmd.Flags().Bool("all", false, "expire the reflogs of all refs")
+	stashCmd.Flags().StringP("message", "m", "", "description of the stash entry")
+	stashCmd.Flags().BoolP("patch", "p", false, "show the stashed changes as a diff")
+	cherryPickCmd.Flags().Bool("continue", false, "continue after resolving conflicts")
+	cherryPickCmd.Flags().Bool("skip", false, "skip the current commit")
+	cherryPickCmd.Flags().Bool("abort", false, "cancel the operation and go back to where it started")
+	cherryPickCmd.Flags().BoolP("gpg-sign", "S", false, "sign the new commits with signing.key from the config")
+	cherryPickCmd.Flags().Bool("no-gpg-sign", false, "do not sign the new commits even if signing.sign is set")
+	revertCmd.Flags().Bool("continue", false, "continue after resolving conflicts")
+	revertCmd.Flags().Bool("skip", false, "skip the current commit")
+	revertCmd.Flags().Bool("abort", false, "cancel the operation and go back to where it started")
+	revertCmd.Flags().BoolP("gpg-sign", "S", false, "sign the new commits with signing.key from the config")
+	revertCmd.Flags().Bool("no-gpg-sign", false, "do not sign the new commits even if signing.sign is set")
+	rebaseCmd.Flags().String("onto", "", "replay the commits on this commit instead of upstream")
+	rebaseCmd.Flags().BoolP("interactive", "i", false, "edit the list of commits to replay before the rebase starts")
+	rebaseCmd.Flags().Bool("autosquash", false, "move fixup! and squash! commits next to the commits they belong to")
+	rebaseCmd.Flags().Bool("continue", false, "continue after resolving conflicts")
+	rebaseCmd.Flags().Bool("skip", false, "skip the current commit")
+	rebaseCmd.Flags().Bool("abort", false, "cancel the rebase and go back to the original branch")
+	rebaseCmd.Flags().BoolP("gpg-sign", "S", false, "sign the rebased commits with signing.key from the config")
+	rebaseCmd.Flags().Bool("no-gpg-sign", false, "do not sign the rebased commits even if signing.sign is set")
+	blameCmd.Flags().StringP("lines", "L", "", "only blame the lines in start,end or start,+count")
+	blameCmd.Flags().Bool("porcelain", false, "show the result in a machine readable format")
+	grepCmd.Flags().BoolP("ignore-case", "i", false, "ignore case differences between the pattern and the files")
+	grepCmd.Flags().BoolP("line-number", "n", false, "prefix each match with its line number")
+	grepCmd.Flags().BoolP("files-with-matches", "l", false, "only show the names of files that match")
+	grepCmd.Flags().BoolP("count", "c", false, "show the number of matching lines per file")
+	grepCmd.Flags().Bool("cached", false, "search the blobs in the index instead of the working tree")
+	archiveCmd.Flags().String("format", "", "archive format: tar, tar.gz or zip (default from --output, else tar)")
+	archiveCmd.Flags().String("prefix", "", "prepend this to every path in the archive, e.g. project/")
+	archiveCmd.Flags().StringP("output", "o", "", "write the archive to this file instead of stdout")
+	remoteCmd.Flags().BoolP("verbose", "v", false, "show the url of each remote")
+	fetchCmd.Flags().BoolP("prune", "p", false, "remove remote-tracking refs whose branch is gone from the remote")
+	pushCmd.Flags().BoolP("force", "f", false, "update the remote branch even if it is not a fast-forward")
+	pushCmd.Flags().BoolP("set-upstream", "u", false, "make the pushed branch track the remote branch")
+	bundleCmd.Flags().Bool("all", false, "bundle every branch and HEAD")
+	tagCmd.Flags().BoolP("annotate", "a", false, "make an annotated tag object")
+	tagCmd.Flags().BoolP("sign", "s", false, "make a signed annotated tag")
+	tagCmd.Flags().Bool("no-sign", false, "do not sign the tag even if signing.sign is set")
+	tagCmd.Flags().StringP("message", "m", "", "message of an annotated tag")
+	tagCmd.Flags().BoolP("force", "f", false, "replace an existing tag")
+	tagCmd.Flags().BoolP("delete", "d", false, "delete the tags")
+	logCmd.Flags().IntP("max-count", "n", -1, "show at most this many commits")
+	logCmd.Flags().Bool("oneline", false, "show each commit on one line")
+	logCmd.Flags().Bool("show-signature", false, "check and show the signature of each commit")
+	serveCmd.Flags().String("addr", "127.0.0.1:8418", "address to listen on")
+	updateRefCmd.Flags().BoolP("delete", "d", false, "delete the ref")
+	updateRefCmd.Flags().Bool("stdin", false, "read updates from stdin and apply them in one transaction")
+	updateRefCmd.Flags().StringP("message", "m", "", "reason recorded in the reflog")
+	forEachRefCmd.Flags().String("format", commands.DefaultRefFormat, "format of each line")
+	forEachRefCmd.Flags().Int("count", 0, "stop after this many refs")
+	packRefsCmd.Flags().Bool("all", false, "pack every ref, not only tags")
+	lfsCmd.Flags().Bool("all", false, "fetch the large files of every commit reachable from a ref")
+	lfsCmd.Flags().BoolP("dry-run", "n", false, "only show what prune would delete")
+	lfsCmd.Flags().BoolP("force", "f", false, "prune large files even when no local remote has them")
+	worktreeCmd.Flags().StringP("branch", "b", "", "create a new branch for the worktree")
+	worktreeCmd.Flags().Bool("detach", false, "check out a detached HEAD even when the commit-ish is a branch")
+	worktreeCmd.Flags().BoolP("force", "f", false, "add a branch checked out elsewhere, or remove a worktree with local changes")
+	worktreeCmd.Flags().BoolP("dry-run", "n", false, "only show what prune would remove")
 
 	rootCmd.AddCommand(initCmd)
 	rootCmd.AddCommand(addCmd)
@@ -78,5 +472,37 @@ func main() {
 	rootCmd.AddCommand(branchCmd)
 	rootCmd.AddCommand(checkoutCmd)
 	rootCmd.AddCommand(mergeCmd)
+	rootCmd.AddCommand(hashObjectCmd)
+	rootCmd.AddCommand(catFileCmd)
+	rootCmd.AddCommand(lsTreeCmd)
+	rootCmd.AddCommand(writeTreeCmd)
+	rootCmd.AddCommand(commitTreeCmd)
+	rootCmd.AddCommand(fsckCmd)
+	rootCmd.AddCommand(pruneCmd)
+	rootCmd.AddCommand(gcCmd)
+	rootCmd.AddCommand(reflogCmd)
+	rootCmd.AddCommand(stashCmd)
+	rootCmd.AddCommand(cherryPickCmd)
+	rootCmd.AddCommand(revertCmd)
+	rootCmd.AddCommand(rebaseCmd)
+	rootCmd.AddCommand(blameCmd)
+	rootCmd.AddCommand(bisectCmd)
+	rootCmd.AddCommand(grepCmd)
+	rootCmd.AddCommand(archiveCmd)
+	rootCmd.AddCommand(cloneCmd)
+	rootCmd.AddCommand(remoteCmd)
+	rootCmd.AddCommand(fetchCmd)
+	rootCmd.AddCommand(pushCmd)
+	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(bundleCmd)
+	rootCmd.AddCommand(tagCmd)
+	rootCmd.AddCommand(logCmd)
+	rootCmd.AddCommand(verifyCommitCmd)
+	rootCmd.AddCommand(verifyTagCmd)
+	rootCmd.AddCommand(updateRefCmd)
+	rootCmd.AddCommand(forEachRefCmd)
+	rootCmd.AddCommand(packRefsCmd)
+	rootCmd.AddCommand(lfsCmd)
+	rootCmd.AddCommand(worktreeCmd)
 	rootCmd.Execute()
 }